@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/allanmaral/gomigrate/migrate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baselineVersion string
+	baselineForce   bool
+	baselineReason  string
+)
+
+// migrationBaselineCmd represents the baseline command
+var migrationBaselineCmd = &cobra.Command{
+	Use:   "baseline",
+	Short: "Record the migrations up to a version as applied without running them",
+	Long: "Create the migrations table and record every local migration up to and including --version as applied, without running it. " +
+		"Use it to adopt gomigrate on a database whose schema already exists, later runs only apply the newer migrations.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if baselineVersion == "" {
+			return fmt.Errorf("--version is required")
+		}
+
+		m, err := newMigrator(migrate.WithReason(baselineReason))
+		if err != nil {
+			return err
+		}
+
+		if _, err := m.Baseline(cmd.Context(), baselineVersion, baselineForce); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrationBaselineCmd)
+
+	migrationBaselineCmd.Flags().StringVar(&baselineVersion, "version", "", "Last migration the existing schema already has")
+	migrationBaselineCmd.Flags().BoolVar(&baselineForce, "force", false, "Baseline even when the migrations table already has history")
+	migrationBaselineCmd.Flags().StringVar(&baselineReason, "reason", "", "Message recorded in the migration history")
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// migrationForceCmd represents the force command
+var migrationForceCmd = &cobra.Command{
+	Use:   "force <version>",
+	Short: "Clear the dirty mark of a migration that failed partway through",
+	Long: "Clear the dirty mark left by a migration that failed partway through, after fixing the database by hand. " +
+		"Pass the last migration the database is fully migrated to: dirty migrations up to and including it are recorded as applied, the ones after it as not applied.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := newMigrator()
+		if err != nil {
+			return err
+		}
+
+		if _, err := m.Force(cmd.Context(), args[0]); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrationForceCmd)
+}
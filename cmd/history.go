@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/allanmaral/gomigrate/migrate"
+	"github.com/spf13/cobra"
+)
+
+var historyFormat string
+
+// migrationHistoryCmd represents the migration history command
+var migrationHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List applied migrations with when, how long and by whom",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if historyFormat != "table" && historyFormat != "json" {
+			return fmt.Errorf("unknown history format %q, expected \"table\" or \"json\"", historyFormat)
+		}
+
+		m, err := newMigrator()
+		if err != nil {
+			return err
+		}
+
+		entries, err := m.History(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if historyFormat == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(entries)
+		}
+
+		return printHistoryTable(entries)
+	},
+}
+
+func printHistoryTable(entries []migrate.HistoryEntry) error {
+	if len(entries) == 0 {
+		fmt.Println("No executed migrations found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED AT\tDURATION\tBY\tVERSION\tREASON")
+	for _, entry := range entries {
+		name := entry.Name
+		if entry.Dirty {
+			name += " (dirty)"
+		}
+
+		appliedAt := ""
+		duration := ""
+		if entry.AppliedAt != nil {
+			appliedAt = entry.AppliedAt.Local().Format(time.RFC3339)
+			duration = (time.Duration(entry.DurationMs) * time.Millisecond).String()
+		}
+
+		by := entry.AppliedBy
+		if entry.Hostname != "" {
+			by += "@" + entry.Hostname
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, appliedAt, duration, by, entry.ToolVersion, entry.Reason)
+	}
+
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(migrationHistoryCmd)
+
+	migrationHistoryCmd.Flags().StringVar(&historyFormat, "format", "table", "Output format (table, json)")
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/allanmaral/gomigrate/migrate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importFlywayTable  string
+	importFlywayReason string
+)
+
+// migrationImportFlywayCmd represents the import flyway history command
+var migrationImportFlywayCmd = &cobra.Command{
+	Use:   "import-flyway",
+	Short: "Record the migrations applied by Flyway as applied",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := newMigrator(migrate.WithReason(importFlywayReason))
+		if err != nil {
+			return err
+		}
+
+		if _, err := m.ImportFlyway(cmd.Context(), importFlywayTable); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrationImportFlywayCmd)
+
+	migrationImportFlywayCmd.Flags().StringVar(&importFlywayTable, "table", "flyway_schema_history", "Flyway schema history table")
+	migrationImportFlywayCmd.Flags().StringVar(&importFlywayReason, "reason", "", "Message recorded in the migration history")
+}
@@ -18,6 +18,7 @@ var (
 	migrationsPath  string
 	migrationsTable string
 	nameColumn      string
+	format          string
 	force           bool
 
 	initCmd = &cobra.Command{
@@ -44,6 +45,7 @@ var (
 			conf := config.Config{
 				Url:            url.String(),
 				MigrationsPath: migrationsPath,
+				Format:         format,
 			}
 
 			if err := config.Init(&conf, force); err != nil {
@@ -63,9 +65,10 @@ func init() {
 	initCmd.Flags().StringVarP(&databaseName, "database", "d", "", "Database name")
 	initCmd.Flags().StringVar(&hostname, "host", "localhost", "Database connection hostname")
 	initCmd.Flags().Int32Var(&port, "port", 0, "Database connection port")
-	initCmd.Flags().StringVar(&provider, "provider", "sqlserver", "Database provider")
+	initCmd.Flags().StringVar(&provider, "provider", "sqlserver", "Database provider (sqlserver, postgres, mysql, sqlite)")
 	initCmd.Flags().StringVar(&migrationsPath, "migrations-path", "migrations", "Path to the migrations folder")
 	initCmd.Flags().StringVar(&migrationsTable, "migrations-table", "schema_migrations", "Migrations table name")
 	initCmd.Flags().StringVar(&nameColumn, "name-column", "name", "Migrations table name column")
+	initCmd.Flags().StringVar(&format, "format", "", "Migration file format (single, paired, flyway), detected from the migrations folder when empty")
 	initCmd.Flags().BoolVarP(&force, "force", "f", false, "Will drop the existing config file and re-create it")
 }
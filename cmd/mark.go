@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/allanmaral/gomigrate/migrate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	markAllowMissing bool
+	markReason       string
+	markYes          bool
+)
+
+// migrationMarkCmd represents the mark command
+var migrationMarkCmd = &cobra.Command{
+	Use:   "mark <name>",
+	Short: "Record a migration as applied without running it",
+	Long:  "Record a migration as applied without running it, for changes made to the database by hand. The migration must exist in the migrations folder unless --allow-missing is given.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := []migrate.Option{migrate.WithReason(markReason)}
+		if !markYes {
+			opts = append(opts, migrate.WithConfirm(confirm))
+		}
+
+		m, err := newMigrator(opts...)
+		if err != nil {
+			return err
+		}
+
+		_, err = m.Mark(cmd.Context(), args[0], markAllowMissing)
+		if errors.Is(err, migrate.ErrAborted) {
+			fmt.Println("Aborted, the migration history was not changed.")
+			return nil
+		}
+
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrationMarkCmd)
+
+	migrationMarkCmd.Flags().BoolVar(&markAllowMissing, "allow-missing", false, "Accept a migration that is not in the migrations folder")
+	migrationMarkCmd.Flags().StringVar(&markReason, "reason", "", "Why the history is changed by hand")
+	migrationMarkCmd.Flags().BoolVarP(&markYes, "yes", "y", false, "Do not ask for confirmation")
+}
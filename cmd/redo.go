@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/allanmaral/gomigrate/migrate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	redoSteps  int
+	redoReason string
+)
+
+// migrationRedoCmd represents the redo migrations command
+var migrationRedoCmd = &cobra.Command{
+	Use:   "redo",
+	Short: "Revert and reapply the latest applied migrations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := newMigrator(migrate.WithReason(redoReason))
+		if err != nil {
+			return err
+		}
+
+		if _, err := m.Redo(cmd.Context(), redoSteps); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrationRedoCmd)
+
+	migrationRedoCmd.Flags().IntVar(&redoSteps, "steps", 1, "Number of applied migrations to redo")
+	migrationRedoCmd.Flags().StringVar(&redoReason, "reason", "", "Message recorded in the migration history")
+}
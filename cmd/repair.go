@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// migrationRepairCmd represents the repair checksums command
+var migrationRepairCmd = &cobra.Command{
+	Use:   "repair [name...]",
+	Short: "Record the current checksum of applied migrations",
+	Long:  "Record the current checksum of applied migrations that were changed on purpose. Without names, every applied migration is repaired.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := newMigrator()
+		if err != nil {
+			return err
+		}
+
+		if _, err := m.Repair(cmd.Context(), args...); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrationRepairCmd)
+}
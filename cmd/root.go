@@ -1,16 +1,23 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/migrate"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-
-	_ "github.com/allanmaral/gomigrate/internal/database/sqlserver"
 )
 
 var (
@@ -21,29 +28,126 @@ var (
 	}
 )
 
-func Execute() {
-	err := rootCmd.Execute()
+var toolVersion string
+
+// Execute runs the gomigrate command line. Programs with Go migrations call
+// it from their own main, after registering them with the gomigration package.
+func Execute(version string) {
+	toolVersion = version
+	rootCmd.Version = version
+
+	ctx, cancel := interruptContext()
+	err := rootCmd.ExecuteContext(ctx)
+	cancel()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// interruptContext returns a context cancelled on the first SIGINT or
+// SIGTERM, which cancels the running statement so the command fails naming
+// the interrupted migration. A second signal kills the process.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(os.Stderr, "Received %s, cancelling the running migration. Send it again to exit immediately.\n", sig)
+			signal.Stop(signals)
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(signals)
+		}
+	}()
+
+	return ctx, cancel
+}
+
 func GetConfig() *config.Config {
 	configDir := filepath.Dir(viper.ConfigFileUsed())
 	migrationsPath := path.Join(configDir, viper.GetString("migrations_path"))
 
 	config := &config.Config{
-		Url:            viper.GetString("url"),
-		MigrationsPath: migrationsPath,
+		Url:              viper.GetString("url"),
+		MigrationsPath:   migrationsPath,
+		LockTimeout:      viper.GetDuration("lock_timeout"),
+		Timeout:          viper.GetDuration("timeout"),
+		MigrationTimeout: viper.GetDuration("migration_timeout"),
+		OutOfOrder:       viper.GetString("out_of_order"),
+		Format:           viper.GetString("format"),
+		Version:          toolVersion,
 	}
 
 	return config
 }
 
+// newMigrator returns a Migrator for the loaded configuration that prints
+// its progress to stdout. Commands writing a script to stdout pass
+// migrate.WithLog(os.Stderr), which takes precedence.
+func newMigrator(opts ...migrate.Option) (*migrate.Migrator, error) {
+	config := GetConfig()
+
+	options := []migrate.Option{
+		migrate.WithLog(os.Stdout),
+		migrate.WithToolVersion(config.Version),
+		migrate.WithLockTimeout(config.LockTimeout),
+		migrate.WithTimeout(config.Timeout),
+		migrate.WithMigrationTimeout(config.MigrationTimeout),
+		migrate.WithOutOfOrder(config.OutOfOrder),
+		migrate.WithFormat(config.Format),
+	}
+
+	return migrate.New(migrate.Dir(config.MigrationsPath), config.Url, append(options, opts...)...)
+}
+
+// openOutput creates the file at path, or returns stdout when path is empty,
+// along with a function closing it.
+func openOutput(path string) (io.Writer, func(), error) {
+	if path == "" {
+		return os.Stdout, func() {}, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create script file \"%s\"", path)
+	}
+
+	return file, func() { file.Close() }, nil
+}
+
+// confirm asks a yes or no question on stdin. Anything but yes is a no.
+func confirm(question string) (bool, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return false, fmt.Errorf("cannot ask for confirmation as stdin is not a terminal, use --yes to proceed")
+	}
+
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is .gomigrate)")
+	rootCmd.PersistentFlags().Duration("lock-timeout", 15*time.Second, "How long to wait for another process to release the migrations lock")
+
+	rootCmd.PersistentFlags().Duration("timeout", 0, "Cancel the command when it takes longer than this (0 for no limit)")
+	rootCmd.PersistentFlags().Duration("migration-timeout", 0, "Cancel a migration when it runs longer than this (0 for no limit)")
+
+	viper.BindPFlag("lock_timeout", rootCmd.PersistentFlags().Lookup("lock-timeout"))
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("migration_timeout", rootCmd.PersistentFlags().Lookup("migration-timeout"))
 }
 
 func initConfig() {
@@ -71,9 +175,9 @@ func initConfig() {
 		path, err := filepath.Rel(wd, viper.ConfigFileUsed())
 
 		if err != nil {
-			fmt.Printf("Loaded configuration file \"%s\"\n", viper.ConfigFileUsed())
+			fmt.Fprintf(os.Stderr, "Loaded configuration file \"%s\"\n", viper.ConfigFileUsed())
 		} else {
-			fmt.Printf("Loaded configuration file \"%s\"\n", path)
+			fmt.Fprintf(os.Stderr, "Loaded configuration file \"%s\"\n", path)
 		}
 	}
 }
@@ -1,25 +1,66 @@
 package cmd
 
 import (
-	"github.com/allanmaral/gomigrate/internal/migration"
+	"fmt"
+	"os"
+
+	"github.com/allanmaral/gomigrate/migrate"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	runReason string
+	runTo     string
+	runSteps  int
+	runDryRun bool
+	runOutput string
 )
 
-// migrationCreateCmd represents the create migration command
+// migrationRunCmd represents the run migrations command
 var migrationRunCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run pending migrations",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		config := GetConfig()
+		opts := []migrate.Option{migrate.WithReason(runReason)}
+
+		if runDryRun || runOutput != "" {
+			out, closeOutput, err := openOutput(runOutput)
+			if err != nil {
+				return err
+			}
+			defer closeOutput()
+			// Progress goes to stderr, so the script is all stdout holds.
+			opts = append(opts, migrate.WithDryRun(out), migrate.WithLog(os.Stderr))
+		}
+
+		m, err := newMigrator(opts...)
+		if err != nil {
+			return err
+		}
 
-		if err := migration.RunMigrations(config); err != nil {
+		result, err := m.Run(cmd.Context(), migrate.Target{To: runTo, Steps: runSteps})
+		if err != nil {
 			return err
 		}
 
+		if runOutput != "" {
+			fmt.Printf("Wrote %d migrations to \"%s\", nothing was executed.\n", len(result.Migrations), runOutput)
+		}
+
 		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(migrationRunCmd)
+
+	migrationRunCmd.Flags().StringVar(&runReason, "reason", "", "Message recorded in the migration history")
+	migrationRunCmd.Flags().StringVar(&runTo, "to", "", "Apply pending migrations up to and including this version")
+	migrationRunCmd.Flags().IntVar(&runSteps, "steps", 0, "Number of pending migrations to apply")
+	migrationRunCmd.Flags().BoolVar(&runDryRun, "dry-run", false, "Print the SQL that would run without executing it")
+	migrationRunCmd.Flags().StringVarP(&runOutput, "output", "o", "", "Write the dry run SQL to this file (implies --dry-run)")
+	migrationRunCmd.Flags().String("out-of-order", "warn", "What to do with pending migrations older than the latest applied one (error, warn, allow)")
+
+	viper.BindPFlag("out_of_order", migrationRunCmd.Flags().Lookup("out-of-order"))
 }
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/allanmaral/gomigrate/migrate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scriptFrom   string
+	scriptTo     string
+	scriptOutput string
+)
+
+// migrationScriptCmd represents the generate script command
+var migrationScriptCmd = &cobra.Command{
+	Use:   "script",
+	Short: "Generate an idempotent SQL script without connecting to the database",
+	Long: "Generate a SQL script that applies the migrations after --from, up to and including --to. " +
+		"Every migration checks the migrations table first, so the script is safe to run more than once. " +
+		"Each migration runs in its own transaction; run the script so that it stops at the first error " +
+		"(psql -v ON_ERROR_STOP=1, sqlcmd -b), or the migrations after a failed one still run.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := newMigrator(migrate.WithLog(os.Stderr))
+		if err != nil {
+			return err
+		}
+
+		// The output is only created once the script is complete, so a
+		// failure does not leave a partial script behind.
+		var script bytes.Buffer
+		migrations, err := m.Script(cmd.Context(), scriptFrom, scriptTo, &script)
+		if err != nil {
+			return err
+		}
+
+		out, closeOutput, err := openOutput(scriptOutput)
+		if err != nil {
+			return err
+		}
+		defer closeOutput()
+
+		if _, err := script.WriteTo(out); err != nil {
+			return err
+		}
+
+		if scriptOutput != "" {
+			fmt.Printf("Wrote %d migrations to \"%s\".\n", len(migrations), scriptOutput)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrationScriptCmd)
+
+	migrationScriptCmd.Flags().StringVar(&scriptFrom, "from", "", "Start after this version (default is the first migration)")
+	migrationScriptCmd.Flags().StringVar(&scriptTo, "to", "", "Stop at this version, inclusive (default is the last migration)")
+	migrationScriptCmd.Flags().StringVarP(&scriptOutput, "output", "o", "", "Write the script to this file instead of stdout")
+}
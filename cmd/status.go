@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/allanmaral/gomigrate/migrate"
+	"github.com/spf13/cobra"
+)
+
+var statusFormat string
+
+// migrationStatusCmd represents the migration status command
+var migrationStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show applied, pending and missing migrations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statusFormat != "table" && statusFormat != "json" {
+			return fmt.Errorf("unknown status format %q, expected \"table\" or \"json\"", statusFormat)
+		}
+
+		m, err := newMigrator()
+		if err != nil {
+			return err
+		}
+
+		statuses, err := m.Status(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if statusFormat == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(statuses)
+		}
+
+		return printStatusTable(statuses)
+	},
+}
+
+func printStatusTable(statuses []migrate.MigrationStatus) error {
+	if len(statuses) == 0 {
+		fmt.Println("No migrations found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tSTATE\tAPPLIED AT")
+	for _, status := range statuses {
+		state := status.State
+		if status.Modified {
+			state += " (modified)"
+		}
+		if status.OutOfOrder {
+			state += " (out of order)"
+		}
+		if status.Dirty {
+			state += " (dirty)"
+		}
+		appliedAt := ""
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", status.Name, state, appliedAt)
+	}
+
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(migrationStatusCmd)
+
+	migrationStatusCmd.Flags().StringVar(&statusFormat, "format", "table", "Output format (table, json)")
+}
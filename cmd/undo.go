@@ -1,23 +1,52 @@
 package cmd
 
 import (
-	"github.com/allanmaral/gomigrate/internal/migration"
+	"fmt"
+	"os"
+
+	"github.com/allanmaral/gomigrate/migrate"
 	"github.com/spf13/cobra"
 )
 
-var revertAll bool
+var (
+	revertAll    bool
+	revertTo     string
+	revertSteps  int
+	revertDryRun bool
+	revertOutput string
+)
 
 // migrationUndoCmd represents the revert migration command
 var migrationUndoCmd = &cobra.Command{
 	Use:   "undo",
 	Short: "Revert applied migration",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		config := GetConfig()
+		opts := []migrate.Option{}
 
-		if err := migration.RevertMigration(revertAll, config); err != nil {
+		if revertDryRun || revertOutput != "" {
+			out, closeOutput, err := openOutput(revertOutput)
+			if err != nil {
+				return err
+			}
+			defer closeOutput()
+			// Progress goes to stderr, so the script is all stdout holds.
+			opts = append(opts, migrate.WithDryRun(out), migrate.WithLog(os.Stderr))
+		}
+
+		m, err := newMigrator(opts...)
+		if err != nil {
 			return err
 		}
 
+		result, err := m.Revert(cmd.Context(), migrate.Target{To: revertTo, Steps: revertSteps, All: revertAll})
+		if err != nil {
+			return err
+		}
+
+		if revertOutput != "" {
+			fmt.Printf("Wrote %d migrations to \"%s\", nothing was executed.\n", len(result.Migrations), revertOutput)
+		}
+
 		return nil
 	},
 }
@@ -26,4 +55,8 @@ func init() {
 	rootCmd.AddCommand(migrationUndoCmd)
 
 	migrationUndoCmd.Flags().BoolVarP(&revertAll, "all", "a", false, "Revert all migrations")
+	migrationUndoCmd.Flags().StringVar(&revertTo, "to", "", "Revert migrations down to, but not including, this version")
+	migrationUndoCmd.Flags().IntVar(&revertSteps, "steps", 0, "Number of applied migrations to revert")
+	migrationUndoCmd.Flags().BoolVar(&revertDryRun, "dry-run", false, "Print the SQL that would run without executing it")
+	migrationUndoCmd.Flags().StringVarP(&revertOutput, "output", "o", "", "Write the dry run SQL to this file (implies --dry-run)")
 }
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/allanmaral/gomigrate/migrate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	unmarkAllowMissing bool
+	unmarkReason       string
+	unmarkYes          bool
+)
+
+// migrationUnmarkCmd represents the unmark command
+var migrationUnmarkCmd = &cobra.Command{
+	Use:   "unmark <name>",
+	Short: "Remove a migration from the history without reverting it",
+	Long:  "Remove an applied migration from the migration history without reverting it, for changes undone by hand. The migration must exist in the migrations folder unless --allow-missing is given. The history row is deleted, and who removed it, when and with what --reason is recorded in the <migrations table>_unmarked table.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := []migrate.Option{migrate.WithReason(unmarkReason)}
+		if !unmarkYes {
+			opts = append(opts, migrate.WithConfirm(confirm))
+		}
+
+		m, err := newMigrator(opts...)
+		if err != nil {
+			return err
+		}
+
+		_, err = m.Unmark(cmd.Context(), args[0], unmarkAllowMissing)
+		if errors.Is(err, migrate.ErrAborted) {
+			fmt.Println("Aborted, the migration history was not changed.")
+			return nil
+		}
+
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrationUnmarkCmd)
+
+	migrationUnmarkCmd.Flags().BoolVar(&unmarkAllowMissing, "allow-missing", false, "Accept a migration that is not in the migrations folder")
+	migrationUnmarkCmd.Flags().StringVar(&unmarkReason, "reason", "", "Why the history is changed by hand")
+	migrationUnmarkCmd.Flags().BoolVarP(&unmarkYes, "yes", "y", false, "Do not ask for confirmation")
+}
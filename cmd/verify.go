@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// migrationVerifyCmd represents the verify migrations command
+var migrationVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that applied migrations were not modified",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := newMigrator()
+		if err != nil {
+			return err
+		}
+
+		if err := m.Verify(cmd.Context()); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrationVerifyCmd)
+}
@@ -0,0 +1,104 @@
+// Package gomigration registers migrations written in Go, for changes that
+// need more than SQL, like backfilling data computed in Go. Registered
+// migrations are ordered and recorded together with the SQL migrations of
+// the migrations folder.
+//
+// Go migrations are compiled in, so they run from a binary built around the
+// gomigrate commands:
+//
+//	package main
+//
+//	import (
+//		"github.com/allanmaral/gomigrate/cmd"
+//
+//		_ "example.com/app/migrations"
+//	)
+//
+//	var version = "dev"
+//
+//	func main() {
+//		cmd.Execute(version)
+//	}
+//
+// where version is recorded in the migration history, and the migrations
+// package registers its migrations in init functions:
+//
+//	func init() {
+//		gomigration.Register("20230801120000", "backfill-payload", upBackfill, downBackfill)
+//	}
+package gomigration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Func is the body of a Go migration. It runs inside the transaction the
+// migration is recorded in, and the migration fails when it returns an error.
+type Func func(ctx context.Context, tx *sql.Tx) error
+
+// Migration is a registered Go migration.
+type Migration struct {
+	// Version orders the migration among the others. It is compared with the
+	// versions of SQL migrations, like "20230801120000" or Flyway's "1.2".
+	Version string
+
+	// Description is a short name for the migration.
+	Description string
+
+	Up Func
+
+	// Down reverts Up. Migrations without one are irreversible.
+	Down Func
+}
+
+// Name is the name the migration is recorded under in the migrations table.
+func (m Migration) Name() string {
+	return m.Version + "-" + m.Description + ".go"
+}
+
+// versionPattern matches the versions Go migrations can be registered under,
+// dot or underscore separated numbers.
+var versionPattern = regexp.MustCompile(`^\d+(?:[._]\d+)*$`)
+
+var migrationsMu sync.RWMutex
+var migrations = make(map[string]Migration)
+
+// Register adds a Go migration under version. It panics when the version is
+// invalid or already registered, or when up is nil, as it is meant to be
+// called from init functions.
+func Register(version string, description string, up Func, down Func) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	if !versionPattern.MatchString(version) {
+		panic(fmt.Sprintf("gomigration: invalid version %q, expected numbers like \"20230801120000\" or \"1.2\"", version))
+	}
+	if description == "" {
+		panic("gomigration: Register called without a description for version " + version)
+	}
+	if up == nil {
+		panic("gomigration: Register called with a nil up function for version " + version)
+	}
+	if _, dup := migrations[version]; dup {
+		panic("gomigration: Register called twice for version " + version)
+	}
+
+	migrations[version] = Migration{Version: version, Description: description, Up: up, Down: down}
+}
+
+// Migrations returns the registered migrations, in no particular order.
+func Migrations() []Migration {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+
+	registered := make([]Migration, 0, len(migrations))
+	for _, migration := range migrations {
+		registered = append(registered, migration)
+	}
+
+	return registered
+}
@@ -2,15 +2,52 @@ package config
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/allanmaral/gomigrate/internal/source"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Url            string `yaml:"url"`
-	MigrationsPath string `yaml:"migrations_path"`
+	Url            string        `yaml:"url"`
+	MigrationsPath string        `yaml:"migrations_path"`
+	LockTimeout    time.Duration `yaml:"lock_timeout,omitempty"`
+
+	// Timeout bounds a whole command and MigrationTimeout each migration it
+	// runs. Zero means no limit.
+	Timeout          time.Duration `yaml:"timeout,omitempty"`
+	MigrationTimeout time.Duration `yaml:"migration_timeout,omitempty"`
+
+	// Format is the layout of the migration files, "single", "paired" or
+	// "flyway". It is detected from the migrations folder when empty.
+	Format string `yaml:"format,omitempty"`
+
+	// OutOfOrder decides what run does with pending migrations older than the
+	// latest applied one: "error", "warn" or "allow".
+	OutOfOrder string `yaml:"out_of_order,omitempty"`
+
+	// Source is where migrations are read from. It is the MigrationsPath
+	// folder when nil.
+	Source source.Source `yaml:"-"`
+
+	// Reason and Version are written to the migration history. They come from
+	// the command line instead of the config file.
+	Reason  string `yaml:"-"`
+	Version string `yaml:"-"`
+
+	// DryRun receives the SQL of a run or undo instead of it being executed,
+	// when set.
+	DryRun io.Writer `yaml:"-"`
+
+	// Log receives progress messages, when set.
+	Log io.Writer `yaml:"-"`
+
+	// Confirm is asked before the history is edited by hand, when set. The
+	// change is only made when it returns true.
+	Confirm func(question string) (bool, error) `yaml:"-"`
 }
 
 func Init(conf *Config, force bool) error {
@@ -1,28 +1,140 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"net/url"
+	"strings"
 	"sync"
+	"time"
 )
 
 var driversMu sync.RWMutex
 var drivers = make(map[string]Driver)
 
 type Driver interface {
+	Scripter
+
 	Url(conf *ConnectionParams) *url.URL
 
-	Open(url string) (Driver, error)
+	// Open connects to the database at url. ctx bounds the connection. The
+	// migrations table is left as it is until EnsureTable is called.
+	Open(ctx context.Context, url string) (Driver, error)
+
+	// EnsureTable creates the migrations table, or adds the history columns
+	// it lacks.
+	EnsureTable(ctx context.Context) error
+
+	// CheckTable reports the state of the migrations table without changing
+	// it, for dry runs and read only commands.
+	CheckTable(ctx context.Context) (TableState, error)
+
+	// NewScripter returns a Scripter for the database at url without
+	// connecting to it.
+	NewScripter(url string) (Scripter, error)
 
 	Close() error
 
-	Run(migration string) error
+	// Run executes a migration on the driver connection. Cancelling ctx
+	// cancels the running statement.
+	Run(ctx context.Context, migration string) error
+
+	AppliedMigrations(ctx context.Context) ([]AppliedMigration, error)
+
+	// AppliedNames reads only the names of the applied migrations, which a
+	// migrations table created by an older version also has.
+	AppliedNames(ctx context.Context) ([]string, error)
+
+	MarkAsApplied(ctx context.Context, migration AppliedMigration) error
+
+	RemoveApplied(ctx context.Context, migration string) error
+
+	// EnsureUnmarkedTable creates the table recording the migrations removed
+	// from the history by hand, named after the migrations table with an
+	// _unmarked suffix. It is only created once a migration is unmarked.
+	EnsureUnmarkedTable(ctx context.Context) error
+
+	// UpdateChecksum replaces the checksum recorded for an applied migration.
+	UpdateChecksum(ctx context.Context, migration string, checksum string) error
+
+	// SetDirty sets or clears the dirty mark of an applied migration.
+	SetDirty(ctx context.Context, migration string, dirty bool) error
+
+	// UpdateApplied replaces the recorded details of an applied migration in
+	// a single statement, like when a migration recorded as dirty completes.
+	UpdateApplied(ctx context.Context, migration AppliedMigration) error
+
+	// Query runs a read only query on the driver connection, for reading the
+	// tables other migration tools leave behind.
+	Query(ctx context.Context, query string) (*sql.Rows, error)
+
+	// Begin starts a transaction on the driver connection, so a migration and
+	// its bookkeeping can be committed or rolled back together. The
+	// transaction is rolled back when ctx is cancelled before it is committed.
+	Begin(ctx context.Context) (Tx, error)
+
+	// Transactional reports whether schema changes made inside a Tx are rolled
+	// back with it. Engines that commit DDL implicitly return false.
+	Transactional() bool
+
+	// Lock takes an exclusive lock on the migrations table, so only one process
+	// migrates the database at a time. It waits up to timeout for another
+	// process to release it and then fails with ErrLocked, or gives up early
+	// when ctx is cancelled.
+	Lock(ctx context.Context, timeout time.Duration) error
+
+	Unlock(ctx context.Context) error
+}
+
+// TableState is the state of the migrations table, as reported by
+// Driver.CheckTable.
+type TableState int
 
-	AppliedMigrations() ([]string, error)
+const (
+	// TableMissing means the migrations table does not exist yet.
+	TableMissing TableState = iota
 
-	MarkAsApplied(migration string) error
+	// TableOutdated means the migrations table lacks some history columns.
+	TableOutdated
 
-	RemoveApplied(migration string) error
+	// TableCurrent means the migrations table has every history column.
+	TableCurrent
+)
+
+// TableUpgradeScripter is implemented by drivers whose EnsureTableScript
+// cannot add the history columns missing from an existing migrations table.
+// UpgradeTableScript returns the statements adding the columns the table
+// lacks, for dry runs, and nothing when none are missing.
+type TableUpgradeScripter interface {
+	UpgradeTableScript(ctx context.Context) (string, error)
+}
+
+type Tx interface {
+	Run(ctx context.Context, migration string) error
+
+	// RunFunc runs a migration written in Go with the underlying transaction,
+	// passing it ctx.
+	RunFunc(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error
+
+	MarkAsApplied(ctx context.Context, migration AppliedMigration) error
+
+	RemoveApplied(ctx context.Context, migration string) error
+
+	// RecordUnmarked adds a row to the table created by EnsureUnmarkedTable.
+	RecordUnmarked(ctx context.Context, unmarked Unmarked) error
+
+	Commit() error
+
+	Rollback() error
+}
+
+// Execer is satisfied by both *sql.Conn and *sql.Tx, so drivers can share
+// the code that runs inside and outside a transaction.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
 }
 
 func Url(conf *ConnectionParams) (*url.URL, error) {
@@ -38,14 +150,14 @@ func Url(conf *ConnectionParams) (*url.URL, error) {
 	return d.Url(conf), nil
 }
 
-func Open(rawUrl string) (Driver, error) {
-	purl, err := url.Parse(rawUrl)
-	if err != nil {
-		return nil, err
+func Open(ctx context.Context, rawUrl string) (Driver, error) {
+	// The scheme is read by hand because some driver urls, like
+	// "sqlite://:memory:", are not valid for url.Parse.
+	provider, _, ok := strings.Cut(rawUrl, "://")
+	if !ok {
+		return nil, fmt.Errorf("database driver: missing driver in url")
 	}
 
-	provider := purl.Scheme
-
 	driversMu.RLock()
 	d, ok := drivers[provider]
 	driversMu.RUnlock()
@@ -53,7 +165,7 @@ func Open(rawUrl string) (Driver, error) {
 		return nil, fmt.Errorf("database driver: unknown driver %v", provider)
 	}
 
-	return d.Open(rawUrl)
+	return d.Open(ctx, rawUrl)
 }
 
 func Register(name string, driver Driver) {
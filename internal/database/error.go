@@ -1,6 +1,13 @@
 package database
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLocked is returned by Driver.Lock when another process holds the
+// migrations lock for longer than the lock timeout.
+var ErrLocked = errors.New("migrations are locked by another process")
 
 type Error struct {
 	Line uint
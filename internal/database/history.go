@@ -0,0 +1,223 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// HistoryColumns lists the columns of the migrations table after the name, in
+// the order used by HistoryValues and ScanAppliedMigrations.
+const HistoryColumns = "checksum, applied_at, duration_ms, tool_version, applied_by, hostname, reason, dirty"
+
+// HistoryColumn is one of the HistoryColumns with its type.
+type HistoryColumn struct {
+	Name string
+	Type string
+}
+
+// HistoryColumnTypes declares the HistoryColumns for an engine, given the
+// types it uses for applied_at and dirty; the other columns have standard
+// types. They are all nullable, as drivers add the ones missing from tables
+// created by older versions, so existing history is kept.
+func HistoryColumnTypes(timestamp string, boolean string) []HistoryColumn {
+	return []HistoryColumn{
+		{"checksum", "VARCHAR(64) NULL"},
+		{"applied_at", timestamp + " NULL"},
+		{"duration_ms", "BIGINT NULL"},
+		{"tool_version", "VARCHAR(64) NULL"},
+		{"applied_by", "VARCHAR(255) NULL"},
+		{"hostname", "VARCHAR(255) NULL"},
+		{"reason", "VARCHAR(1000) NULL"},
+		{"dirty", boolean + " NULL"},
+	}
+}
+
+// AppliedMigration is a row of the migrations table. Every field but the name
+// is empty for migrations recorded by older versions.
+type AppliedMigration struct {
+	Name string
+
+	// Checksum is the hash of the migration content when it was applied.
+	Checksum string
+
+	AppliedAt time.Time
+
+	// Duration is how long the migration took to run.
+	Duration time.Duration
+
+	// ToolVersion is the gomigrate version that applied the migration.
+	ToolVersion string
+
+	// AppliedBy and Hostname are the OS user and machine that applied it.
+	AppliedBy string
+	Hostname  string
+
+	// Reason is an optional message given when the migration was applied.
+	Reason string
+
+	// Dirty is set while a migration that cannot be rolled back runs, and
+	// left set when it fails, as the database may be partially migrated.
+	Dirty bool
+}
+
+// UnmarkedColumns lists the columns of the unmarked table after the name, in
+// the order used by UnmarkedValues.
+const UnmarkedColumns = "unmarked_at, tool_version, unmarked_by, hostname, reason"
+
+// Unmarked is a row of the unmarked table, which records the migrations
+// removed from the migrations table by hand, as their own rows are deleted.
+type Unmarked struct {
+	Name string
+
+	UnmarkedAt time.Time
+
+	// ToolVersion is the gomigrate version that removed the migration.
+	ToolVersion string
+
+	// UnmarkedBy and Hostname are the OS user and machine that removed it.
+	UnmarkedBy string
+	Hostname   string
+
+	// Reason is the message given when the migration was removed.
+	Reason string
+}
+
+// UnmarkedValues returns the values of the UnmarkedColumns, for inserts.
+func (u Unmarked) UnmarkedValues() []any {
+	return []any{
+		u.UnmarkedAt.UTC(),
+		u.ToolVersion,
+		u.UnmarkedBy,
+		u.Hostname,
+		u.Reason,
+	}
+}
+
+// HistoryValues returns the values of the HistoryColumns, for inserts.
+func (m AppliedMigration) HistoryValues() []any {
+	return []any{
+		m.Checksum,
+		m.AppliedAt.UTC(),
+		m.Duration.Milliseconds(),
+		m.ToolVersion,
+		m.AppliedBy,
+		m.Hostname,
+		m.Reason,
+		m.Dirty,
+	}
+}
+
+// HistoryAssignments renders "column = placeholder" for each of the
+// HistoryColumns, for updates taking the HistoryValues. placeholder returns
+// the bind parameter of the i-th value, counting from 1.
+func HistoryAssignments(placeholder func(i int) string) string {
+	columns := strings.Split(HistoryColumns, ", ")
+	for i, column := range columns {
+		columns[i] = column + " = " + placeholder(i+1)
+	}
+
+	return strings.Join(columns, ", ")
+}
+
+// HistoryLiterals renders the values of the HistoryColumns as SQL literals,
+// for scripts that are run by hand. now is the engine expression for the
+// current time and quote turns a string into a literal. The duration is left
+// NULL, as it is not known up front, and so is the dirty mark, as scripts are
+// never left half applied by gomigrate.
+func (m AppliedMigration) HistoryLiterals(now string, quote func(string) string) string {
+	return strings.Join([]string{
+		quote(m.Checksum),
+		now,
+		"NULL",
+		quote(m.ToolVersion),
+		quote(m.AppliedBy),
+		quote(m.Hostname),
+		quote(m.Reason),
+		"NULL",
+	}, ", ")
+}
+
+// QuoteLiteral quotes s as a standard SQL string literal.
+func QuoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// ScanAppliedMigrations reads rows selecting the name followed by the
+// HistoryColumns.
+func ScanAppliedMigrations(rows *sql.Rows) ([]AppliedMigration, error) {
+	defer rows.Close()
+
+	migrations := []AppliedMigration{}
+	for rows.Next() {
+		var name string
+		var checksum, toolVersion, appliedBy, hostname, reason sql.NullString
+		var appliedAt sql.NullTime
+		var duration sql.NullInt64
+		var dirty sql.NullBool
+
+		if err := rows.Scan(&name, &checksum, &appliedAt, &duration, &toolVersion, &appliedBy, &hostname, &reason, &dirty); err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, AppliedMigration{
+			Name:        name,
+			Checksum:    checksum.String,
+			AppliedAt:   appliedAt.Time,
+			Duration:    time.Duration(duration.Int64) * time.Millisecond,
+			ToolVersion: toolVersion.String,
+			AppliedBy:   appliedBy.String,
+			Hostname:    hostname.String,
+			Reason:      reason.String,
+			Dirty:       dirty.Bool,
+		})
+	}
+
+	return migrations, rows.Err()
+}
+
+// ScanAppliedNames reads rows selecting only the name of the migrations.
+func ScanAppliedNames(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// ScanTableState reads rows selecting the column names of the migrations
+// table, and reports whether the table exists and has every HistoryColumns.
+func ScanTableState(rows *sql.Rows) (TableState, error) {
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return TableMissing, err
+		}
+		columns[strings.ToLower(name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return TableMissing, err
+	}
+
+	if len(columns) == 0 {
+		return TableMissing, nil
+	}
+
+	for _, column := range strings.Split(HistoryColumns, ", ") {
+		if !columns[column] {
+			return TableOutdated, nil
+		}
+	}
+
+	return TableCurrent, nil
+}
@@ -0,0 +1,518 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"math"
+	nurl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/database"
+	"github.com/go-sql-driver/mysql"
+)
+
+func init() {
+	db := &MySQL{}
+	database.Register("mysql", db)
+	database.Register("mariadb", db)
+}
+
+var DefaultMigrationsTable = "schema_migrations"
+var DefaultMigrationsNameColumn = "name"
+
+var (
+	ErrNilConfig      = fmt.Errorf("no config")
+	ErrNoDatabaseName = fmt.Errorf("no database name")
+)
+
+// errorLinePattern matches the line MySQL reports for syntax errors, which is
+// relative to the statement that failed.
+var errorLinePattern = regexp.MustCompile(`at line (\d+)$`)
+
+// historyColumns declares the history columns with MySQL types.
+var historyColumns = database.HistoryColumnTypes("DATETIME(6)", "BOOLEAN")
+
+type Config struct {
+	MigrationsTable      string
+	MigrationsNameColumn string
+	DatabaseName         string
+}
+
+type MySQL struct {
+	conn *sql.Conn
+	db   *sql.DB
+
+	config *Config
+}
+
+func WithInstance(ctx context.Context, instance *sql.DB, config *Config) (database.Driver, error) {
+	if config == nil {
+		return nil, ErrNilConfig
+	}
+
+	if err := instance.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if config.DatabaseName == "" {
+		query := `SELECT DATABASE()`
+		var databaseName sql.NullString
+		if err := instance.QueryRowContext(ctx, query).Scan(&databaseName); err != nil {
+			return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+
+		if len(databaseName.String) == 0 {
+			return nil, ErrNoDatabaseName
+		}
+
+		config.DatabaseName = databaseName.String
+	}
+
+	if len(config.MigrationsTable) == 0 {
+		config.MigrationsTable = DefaultMigrationsTable
+	}
+
+	if len(config.MigrationsNameColumn) == 0 {
+		config.MigrationsNameColumn = DefaultMigrationsNameColumn
+	}
+
+	conn, err := instance.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MySQL{
+		conn:   conn,
+		db:     instance,
+		config: config,
+	}
+
+	return m, nil
+}
+
+func (m *MySQL) Url(conf *database.ConnectionParams) *nurl.URL {
+	user := conf.User
+	password := conf.Password
+	hostname := conf.Hostname
+	port := conf.Port
+	database := conf.Database
+	tableName := conf.MigrationsTable
+	nameColumn := conf.MigrationsNameColumn
+
+	if user == "" {
+		user = "root"
+	}
+	if port == 0 {
+		port = 3306
+	}
+
+	query := nurl.Values{}
+	query.Add("x-migrations-table", tableName)
+	query.Add("x-name-column", nameColumn)
+
+	return &nurl.URL{
+		Scheme:   "mysql",
+		User:     nurl.UserPassword(user, password),
+		Host:     fmt.Sprintf("%s:%d", hostname, port),
+		Path:     "/" + database,
+		RawQuery: query.Encode(),
+	}
+}
+
+func (m *MySQL) Open(ctx context.Context, url string) (database.Driver, error) {
+	purl, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationsTable := purl.Query().Get("x-migrations-table")
+	nameColumn := purl.Query().Get("x-name-column")
+
+	filteredUrl := database.RemoveCustomQuery(purl)
+
+	// The remaining query parameters are handed to the mysql driver as is, so
+	// options like "tls" or "parseTime" keep working.
+	dsn, err := mysql.ParseDSN("/?" + filteredUrl.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn.User = purl.User.Username()
+	dsn.Passwd, _ = purl.User.Password()
+	dsn.Net = "tcp"
+	dsn.Addr = purl.Host
+	dsn.DBName = strings.TrimPrefix(purl.Path, "/")
+	dsn.ParseTime = true
+
+	db, err := sql.Open("mysql", dsn.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := WithInstance(ctx, db, &Config{
+		DatabaseName:         dsn.DBName,
+		MigrationsTable:      migrationsTable,
+		MigrationsNameColumn: nameColumn,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return driver, nil
+}
+
+func (m *MySQL) Close() error {
+	connErr := m.conn.Close()
+	dbErr := m.db.Close()
+	if connErr != nil || dbErr != nil {
+		return fmt.Errorf("conn: %v, db: %v", connErr, dbErr)
+	}
+	return nil
+}
+
+// Run executes the migration one statement at a time. MySQL commits DDL
+// implicitly, so when a statement fails the ones before it stay applied and
+// the error lists them.
+func (m *MySQL) Run(ctx context.Context, migration string) error {
+	return m.run(ctx, m.conn, migration)
+}
+
+func (m *MySQL) run(ctx context.Context, ex database.Execer, migration string) error {
+	statements := splitStatements(migration)
+
+	for i, stmt := range statements {
+		if _, err := ex.ExecContext(ctx, stmt.Query); err != nil {
+			line := stmt.Line
+			message := err.Error()
+			if myErr, ok := err.(*mysql.MySQLError); ok {
+				message = fmt.Sprintf("%s (error %d, SQLSTATE %s)", myErr.Message, myErr.Number, string(myErr.SQLState[:]))
+				if match := errorLinePattern.FindStringSubmatch(myErr.Message); match != nil {
+					if offset, err := strconv.Atoi(match[1]); err == nil && offset > 0 {
+						line += uint(offset) - 1
+					}
+				}
+			}
+
+			message = fmt.Sprintf("migration failed in statement %d of %d: %s; %s",
+				i+1, len(statements), message, executedSummary(statements[:i]))
+
+			return database.Error{OrigErr: err, Err: message, Query: []byte(stmt.Query), Line: line}
+		}
+	}
+
+	return nil
+}
+
+func (m *MySQL) AppliedMigrations(ctx context.Context) ([]database.AppliedMigration, error) {
+	rows, err := m.conn.QueryContext(
+		ctx,
+		`SELECT `+m.quotedNameColumn()+`, `+database.HistoryColumns+` FROM `+m.quotedTable()+` ORDER BY `+m.quotedNameColumn()+`;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.ScanAppliedMigrations(rows)
+}
+
+func (m *MySQL) AppliedNames(ctx context.Context) ([]string, error) {
+	rows, err := m.conn.QueryContext(ctx, `SELECT `+m.quotedNameColumn()+` FROM `+m.quotedTable()+` ORDER BY `+m.quotedNameColumn()+`;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.ScanAppliedNames(rows)
+}
+
+func (m *MySQL) MarkAsApplied(ctx context.Context, migration database.AppliedMigration) error {
+	return m.markAsApplied(ctx, m.conn, migration)
+}
+
+func (m *MySQL) markAsApplied(ctx context.Context, ex database.Execer, migration database.AppliedMigration) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`INSERT INTO `+m.quotedTable()+` (`+m.quotedNameColumn()+`, `+database.HistoryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		append([]any{migration.Name}, migration.HistoryValues()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to mark migration as applied")
+	}
+
+	return nil
+}
+
+func (m *MySQL) RemoveApplied(ctx context.Context, migration string) error {
+	return m.removeApplied(ctx, m.conn, migration)
+}
+
+func (m *MySQL) removeApplied(ctx context.Context, ex database.Execer, migration string) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`DELETE FROM `+m.quotedTable()+` WHERE `+m.quotedNameColumn()+` = ?;`,
+		migration)
+	if err != nil {
+		return fmt.Errorf("failed to remove applied migration")
+	}
+
+	return nil
+}
+
+func (m *MySQL) EnsureUnmarkedTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS ` + m.quotedUnmarkedTable() + `
+		(
+				` + m.quotedNameColumn() + ` VARCHAR(255) NOT NULL,
+				unmarked_at DATETIME(6) NOT NULL,
+				tool_version VARCHAR(64) NULL,
+				unmarked_by VARCHAR(255) NULL,
+				hostname VARCHAR(255) NULL,
+				reason VARCHAR(1000) NULL
+		);`
+
+	if _, err := m.conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return nil
+}
+
+func (m *MySQL) recordUnmarked(ctx context.Context, ex database.Execer, unmarked database.Unmarked) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`INSERT INTO `+m.quotedUnmarkedTable()+` (`+m.quotedNameColumn()+`, `+database.UnmarkedColumns+`) VALUES (?, ?, ?, ?, ?, ?);`,
+		append([]any{unmarked.Name}, unmarked.UnmarkedValues()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to record unmarked migration")
+	}
+
+	return nil
+}
+
+func (m *MySQL) UpdateChecksum(ctx context.Context, migration string, checksum string) error {
+	_, err := m.conn.ExecContext(
+		ctx,
+		`UPDATE `+m.quotedTable()+` SET checksum = ? WHERE `+m.quotedNameColumn()+` = ?;`,
+		checksum, migration)
+	if err != nil {
+		return fmt.Errorf("failed to update migration checksum")
+	}
+
+	return nil
+}
+
+func (m *MySQL) SetDirty(ctx context.Context, migration string, dirty bool) error {
+	_, err := m.conn.ExecContext(
+		ctx,
+		`UPDATE `+m.quotedTable()+` SET dirty = ? WHERE `+m.quotedNameColumn()+` = ?;`,
+		dirty, migration)
+	if err != nil {
+		return fmt.Errorf("failed to update migration dirty mark")
+	}
+
+	return nil
+}
+
+func (m *MySQL) UpdateApplied(ctx context.Context, migration database.AppliedMigration) error {
+	_, err := m.conn.ExecContext(
+		ctx,
+		`UPDATE `+m.quotedTable()+` SET `+database.HistoryAssignments(func(int) string { return "?" })+` WHERE `+m.quotedNameColumn()+` = ?;`,
+		append(migration.HistoryValues(), migration.Name)...)
+	if err != nil {
+		return fmt.Errorf("failed to update applied migration")
+	}
+
+	return nil
+}
+
+func (m *MySQL) Query(ctx context.Context, query string) (*sql.Rows, error) {
+	return m.conn.QueryContext(ctx, query)
+}
+
+func (m *MySQL) Begin(ctx context.Context) (database.Tx, error) {
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mysqlTx{tx: tx, m: m}, nil
+}
+
+// Transactional is false because MySQL commits DDL statements implicitly, so
+// only data changes made inside a transaction can be rolled back.
+func (m *MySQL) Transactional() bool {
+	return false
+}
+
+// Lock takes a named lock with GET_LOCK, which is released by Unlock or when
+// the connection closes.
+func (m *MySQL) Lock(ctx context.Context, timeout time.Duration) error {
+	query := `SELECT GET_LOCK(?, ?);`
+
+	var result sql.NullInt64
+	seconds := int64(math.Ceil(timeout.Seconds()))
+	if err := m.conn.QueryRowContext(ctx, query, m.lockName(), seconds).Scan(&result); err != nil {
+		return &database.Error{OrigErr: err, Err: "failed to take migrations lock", Query: []byte(query)}
+	}
+
+	// GET_LOCK returns 1 when the lock is granted, 0 on timeout and NULL when
+	// something else went wrong.
+	if !result.Valid {
+		return fmt.Errorf("failed to take migrations lock %s", m.lockName())
+	}
+	if result.Int64 != 1 {
+		return fmt.Errorf("%w (waited %s for lock %s)", database.ErrLocked, timeout, m.lockName())
+	}
+
+	return nil
+}
+
+func (m *MySQL) Unlock(ctx context.Context) error {
+	query := `SELECT RELEASE_LOCK(?);`
+	if _, err := m.conn.ExecContext(ctx, query, m.lockName()); err != nil {
+		return &database.Error{OrigErr: err, Err: "failed to release migrations lock", Query: []byte(query)}
+	}
+
+	return nil
+}
+
+// lockName builds the GET_LOCK name, which MySQL limits to 64 characters.
+func (m *MySQL) lockName() string {
+	name := "gomigrate:" + m.config.DatabaseName + "." + m.config.MigrationsTable
+	if len(name) > 64 {
+		name = fmt.Sprintf("gomigrate:%x", crc32.ChecksumIEEE([]byte(name)))
+	}
+	return name
+}
+
+func (m *MySQL) EnsureTable(ctx context.Context) error {
+	return m.ensureMigrationsTable(ctx)
+}
+
+func (m *MySQL) CheckTable(ctx context.Context) (database.TableState, error) {
+	query := `SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?;`
+
+	rows, err := m.conn.QueryContext(ctx, query, m.config.MigrationsTable)
+	if err != nil {
+		return database.TableMissing, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return database.ScanTableState(rows)
+}
+
+func (m *MySQL) ensureMigrationsTable(ctx context.Context) error {
+	query := m.createTableQuery()
+
+	if _, err := m.conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return m.ensureHistoryColumns(ctx)
+}
+
+func (m *MySQL) createTableQuery() string {
+	return `CREATE TABLE IF NOT EXISTS ` + m.quotedTable() + `
+		(
+				` + m.quotedNameColumn() + ` VARCHAR(255) NOT NULL PRIMARY KEY
+		);`
+}
+
+func (m *MySQL) ensureHistoryColumns(ctx context.Context) error {
+	for _, column := range historyColumns {
+		query := `SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?;`
+
+		var count int
+		if err := m.conn.QueryRowContext(ctx, query, m.config.MigrationsTable, column.Name).Scan(&count); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		query = `ALTER TABLE ` + m.quotedTable() + ` ADD COLUMN ` + column.Name + ` ` + column.Type + `;`
+		if _, err := m.conn.ExecContext(ctx, query); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+	}
+
+	return nil
+}
+
+func (m *MySQL) quotedTable() string {
+	return quoteIdentifier(m.config.MigrationsTable)
+}
+
+func (m *MySQL) quotedUnmarkedTable() string {
+	return quoteIdentifier(m.config.MigrationsTable + "_unmarked")
+}
+
+func (m *MySQL) quotedNameColumn() string {
+	return quoteIdentifier(m.config.MigrationsNameColumn)
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteLiteral quotes s as a string literal. MySQL treats backslashes in
+// strings as escapes, so they are escaped too.
+func quoteLiteral(s string) string {
+	return database.QuoteLiteral(strings.ReplaceAll(s, `\`, `\\`))
+}
+
+// executedSummary describes which statements had already run before a failure.
+func executedSummary(executed []statement) string {
+	if len(executed) == 0 {
+		return "no statements were executed"
+	}
+
+	if len(executed) == 1 {
+		return fmt.Sprintf("statement 1 (starting at line %d) was already executed and was NOT rolled back", executed[0].Line)
+	}
+
+	lines := make([]string, len(executed))
+	for i, stmt := range executed {
+		lines[i] = strconv.FormatUint(uint64(stmt.Line), 10)
+	}
+
+	return fmt.Sprintf("statements 1-%d (starting at lines %s) were already executed and were NOT rolled back",
+		len(executed), strings.Join(lines, ", "))
+}
+
+type mysqlTx struct {
+	tx *sql.Tx
+	m  *MySQL
+}
+
+func (t *mysqlTx) Run(ctx context.Context, migration string) error {
+	return t.m.run(ctx, t.tx, migration)
+}
+
+func (t *mysqlTx) RunFunc(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return fn(ctx, t.tx)
+}
+
+func (t *mysqlTx) MarkAsApplied(ctx context.Context, migration database.AppliedMigration) error {
+	return t.m.markAsApplied(ctx, t.tx, migration)
+}
+
+func (t *mysqlTx) RemoveApplied(ctx context.Context, migration string) error {
+	return t.m.removeApplied(ctx, t.tx, migration)
+}
+
+func (t *mysqlTx) RecordUnmarked(ctx context.Context, unmarked database.Unmarked) error {
+	return t.m.recordUnmarked(ctx, t.tx, unmarked)
+}
+
+func (t *mysqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *mysqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
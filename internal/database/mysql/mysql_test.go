@@ -0,0 +1,29 @@
+package mysql
+
+import "testing"
+
+func TestExecutedSummary(t *testing.T) {
+	tests := []struct {
+		executed []statement
+		want     string
+	}{
+		{
+			executed: nil,
+			want:     "no statements were executed",
+		},
+		{
+			executed: []statement{{Query: "CREATE TABLE a (id int)", Line: 1}},
+			want:     "statement 1 (starting at line 1) was already executed and was NOT rolled back",
+		},
+		{
+			executed: []statement{{Query: "CREATE TABLE a (id int)", Line: 2}, {Query: "CREATE TABLE b (id int)", Line: 7}},
+			want:     "statements 1-2 (starting at lines 2, 7) were already executed and were NOT rolled back",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := executedSummary(tt.executed); got != tt.want {
+			t.Errorf("executedSummary(%v) = %q, want %q", tt.executed, got, tt.want)
+		}
+	}
+}
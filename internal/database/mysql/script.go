@@ -0,0 +1,92 @@
+package mysql
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+// scriptProcedure is the temporary procedure scripts use to run statements
+// conditionally, as MySQL only allows IF inside stored programs.
+const scriptProcedure = "gomigrate_script_step"
+
+// storedProgramPattern matches statements creating stored programs, which
+// MySQL does not allow inside another stored program.
+var storedProgramPattern = regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:DEFINER\s*=\s*\S+\s+)?(?:PROCEDURE|FUNCTION|TRIGGER|EVENT)\b`)
+
+func (m *MySQL) NewScripter(url string) (database.Scripter, error) {
+	table, nameColumn, err := database.ScriptTable(url, DefaultMigrationsTable, DefaultMigrationsNameColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MySQL{config: &Config{MigrationsTable: table, MigrationsNameColumn: nameColumn}}, nil
+}
+
+func (m *MySQL) EnsureTableScript() string {
+	statements := []string{}
+	for _, column := range historyColumns {
+		statements = append(statements, `IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = `+quoteLiteral(m.config.MigrationsTable)+` AND column_name = `+quoteLiteral(column.Name)+`) THEN
+		ALTER TABLE `+m.quotedTable()+` ADD COLUMN `+column.Name+` `+column.Type+`;
+	END IF;`)
+	}
+
+	return m.createTableQuery() + "\n" + procedureScript(strings.Join(statements, "\n\t"))
+}
+
+func (m *MySQL) MarkAsAppliedScript(migration database.AppliedMigration) string {
+	return m.markAsAppliedStatement(migration) + "\n"
+}
+
+func (m *MySQL) RemoveAppliedScript(migration string) string {
+	return `DELETE FROM ` + m.quotedTable() + ` WHERE ` + m.quotedNameColumn() + ` = ` + quoteLiteral(migration) + `;` + "\n"
+}
+
+// GuardedScript places the migration statements inside a temporary procedure
+// behind an existence check. Bodies that change the DELIMITER themselves, or
+// create stored programs, cannot run in a stored program and are refused.
+func (m *MySQL) GuardedScript(migration database.AppliedMigration, body string) (string, error) {
+	for _, line := range strings.Split(body, "\n") {
+		if _, ok := delimiterCommand(strings.TrimSpace(line)); ok {
+			return "", errors.New("DELIMITER commands cannot be used in a script, use \"gomigrate run --dry-run\" instead")
+		}
+	}
+
+	statements := splitStatements(body)
+	for _, stmt := range statements {
+		if storedProgramPattern.MatchString(stmt.Query) {
+			return "", errors.New("stored procedures, functions, triggers and events cannot be created in a script, use \"gomigrate run --dry-run\" instead")
+		}
+	}
+
+	var block strings.Builder
+	block.WriteString("IF NOT EXISTS (SELECT 1 FROM " + m.quotedTable() + " WHERE " + m.quotedNameColumn() + " = " + quoteLiteral(migration.Name) + ") THEN\n")
+
+	for _, stmt := range statements {
+		block.WriteString("\t\t" + stmt.Query + ";\n")
+	}
+
+	block.WriteString("\t\t" + m.markAsAppliedStatement(migration) + "\n")
+	block.WriteString("\tEND IF;")
+
+	return procedureScript(block.String()), nil
+}
+
+func (m *MySQL) markAsAppliedStatement(migration database.AppliedMigration) string {
+	return `INSERT INTO ` + m.quotedTable() + ` (` + m.quotedNameColumn() + `, ` + database.HistoryColumns + `) VALUES (` +
+		quoteLiteral(migration.Name) + `, ` + migration.HistoryLiterals("UTC_TIMESTAMP(6)", quoteLiteral) + `);`
+}
+
+// procedureScript runs body once through a temporary procedure, written for
+// the mysql client, which understands DELIMITER.
+func procedureScript(body string) string {
+	return "DROP PROCEDURE IF EXISTS " + scriptProcedure + ";\n" +
+		"DELIMITER $$\n" +
+		"CREATE PROCEDURE " + scriptProcedure + "()\nBEGIN\n\t" + body + "\nEND $$\n" +
+		"DELIMITER ;\n" +
+		"CALL " + scriptProcedure + "();\n" +
+		"DROP PROCEDURE " + scriptProcedure + ";\n"
+}
@@ -0,0 +1,150 @@
+package mysql
+
+import (
+	"strings"
+	"unicode"
+)
+
+// statement is a single query taken from a migration body, along with the
+// line of the body it starts on.
+type statement struct {
+	Query string
+	Line  uint
+}
+
+// splitStatements breaks a migration body into the statements MySQL expects
+// one at a time. Delimiters inside quotes, identifiers and comments are
+// ignored, and "DELIMITER" lines change the delimiter the way the mysql client
+// does, so procedure and trigger bodies can contain semicolons.
+func splitStatements(body string) []statement {
+	statements := []statement{}
+	delimiter := ";"
+	line := uint(1)
+
+	var current strings.Builder
+	startLine := uint(0)
+
+	flush := func() {
+		query := strings.TrimSpace(current.String())
+		if query != "" {
+			statements = append(statements, statement{Query: query, Line: startLine})
+		}
+		current.Reset()
+		startLine = 0
+	}
+
+	for i := 0; i < len(body); {
+		c := body[i]
+
+		if startLine == 0 && !unicode.IsSpace(rune(c)) {
+			if rest, ok := delimiterCommand(body[i:]); ok {
+				lineEnd := strings.IndexByte(body[i:], '\n')
+				if lineEnd < 0 {
+					lineEnd = len(body) - i
+				}
+				delimiter = rest
+				i += lineEnd
+				continue
+			}
+			startLine = line
+		}
+
+		switch {
+		case c == '\n':
+			line++
+			current.WriteByte(c)
+			i++
+
+		case c == '\'' || c == '"' || c == '`':
+			end := closingQuote(body, i)
+			line += uint(strings.Count(body[i:end], "\n"))
+			current.WriteString(body[i:end])
+			i = end
+
+		case c == '#' || strings.HasPrefix(body[i:], "-- ") || strings.HasPrefix(body[i:], "--\t") || strings.HasPrefix(body[i:], "--\n"):
+			end := strings.IndexByte(body[i:], '\n')
+			if end < 0 {
+				end = len(body) - i
+			}
+			if strings.TrimSpace(current.String()) == "" {
+				startLine = 0
+			}
+			i += end
+
+		case strings.HasPrefix(body[i:], "/*"):
+			end := strings.Index(body[i+2:], "*/")
+			if end < 0 {
+				end = len(body)
+			} else {
+				end = i + 2 + end + 2
+			}
+			comment := body[i:end]
+			line += uint(strings.Count(comment, "\n"))
+			// Executable comments (/*! ... */) are part of the statement.
+			if strings.HasPrefix(comment, "/*!") {
+				current.WriteString(comment)
+			} else if strings.TrimSpace(current.String()) == "" {
+				startLine = 0
+			}
+			i = end
+
+		case strings.HasPrefix(body[i:], delimiter):
+			flush()
+			i += len(delimiter)
+
+		default:
+			current.WriteByte(c)
+			i++
+		}
+	}
+
+	flush()
+
+	return statements
+}
+
+// delimiterCommand reports whether text starts with a DELIMITER command and
+// returns the new delimiter.
+func delimiterCommand(text string) (string, bool) {
+	const keyword = "DELIMITER"
+	if len(text) <= len(keyword) || !strings.EqualFold(text[:len(keyword)], keyword) {
+		return "", false
+	}
+
+	rest := text[len(keyword):]
+	if rest[0] != ' ' && rest[0] != '\t' {
+		return "", false
+	}
+
+	if end := strings.IndexByte(rest, '\n'); end >= 0 {
+		rest = rest[:end]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	return fields[0], true
+}
+
+// closingQuote returns the index just past the quote that closes the one
+// opened at start, honouring doubled quotes and backslash escapes.
+func closingQuote(body string, start int) int {
+	quote := body[start]
+	for i := start + 1; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(body) && body[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(body)
+}
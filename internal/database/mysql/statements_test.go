@@ -0,0 +1,130 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []statement
+	}{
+		{
+			name: "empty",
+			body: "\n  \n",
+			want: []statement{},
+		},
+		{
+			name: "one statement per line",
+			body: "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want: []statement{
+				{Query: "CREATE TABLE a (id int)", Line: 1},
+				{Query: "CREATE TABLE b (id int)", Line: 2},
+			},
+		},
+		{
+			name: "last statement without semicolon",
+			body: "SELECT 1;\nSELECT 2\n",
+			want: []statement{
+				{Query: "SELECT 1", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name: "statement spanning lines",
+			body: "\nCREATE TABLE a (\n\tid int\n);\nSELECT 1;",
+			want: []statement{
+				{Query: "CREATE TABLE a (\n\tid int\n)", Line: 2},
+				{Query: "SELECT 1", Line: 5},
+			},
+		},
+		{
+			name: "semicolons in strings and identifiers",
+			body: "INSERT INTO `a;b` VALUES ('x;y', \"it\"\";\");\nSELECT 1;",
+			want: []statement{
+				{Query: "INSERT INTO `a;b` VALUES ('x;y', \"it\"\";\")", Line: 1},
+				{Query: "SELECT 1", Line: 2},
+			},
+		},
+		{
+			name: "backslash escaped quote",
+			body: "SELECT 'it\\';s';\nSELECT 2;",
+			want: []statement{
+				{Query: "SELECT 'it\\';s'", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name: "comment lines before a statement",
+			body: "-- first\n# second; still a comment\n/* third; */\nCREATE TABLE a (id int);",
+			want: []statement{
+				{Query: "CREATE TABLE a (id int)", Line: 4},
+			},
+		},
+		{
+			name: "double dash without a space is not a comment",
+			body: "SELECT 1--1;\nSELECT 2;",
+			want: []statement{
+				{Query: "SELECT 1--1", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name: "executable comment",
+			body: "/*!40101 SET NAMES utf8 */;\nSELECT 1;",
+			want: []statement{
+				{Query: "/*!40101 SET NAMES utf8 */", Line: 1},
+				{Query: "SELECT 1", Line: 2},
+			},
+		},
+		{
+			name: "delimiter command",
+			body: "DELIMITER $$\nCREATE PROCEDURE p()\nBEGIN\n\tSELECT 1;\n\tSELECT 2;\nEND $$\nDELIMITER ;\nCALL p();",
+			want: []statement{
+				{Query: "CREATE PROCEDURE p()\nBEGIN\n\tSELECT 1;\n\tSELECT 2;\nEND", Line: 2},
+				{Query: "CALL p()", Line: 8},
+			},
+		},
+		{
+			name: "lowercase delimiter command",
+			body: "delimiter //\nSELECT 1; SELECT 2//\n",
+			want: []statement{
+				{Query: "SELECT 1; SELECT 2", Line: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q)\n got %#v\nwant %#v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelimiterCommand(t *testing.T) {
+	tests := []struct {
+		text      string
+		delimiter string
+		ok        bool
+	}{
+		{text: "DELIMITER $$", delimiter: "$$", ok: true},
+		{text: "delimiter //\nSELECT 1//", delimiter: "//", ok: true},
+		{text: "DELIMITER\t;  ", delimiter: ";", ok: true},
+		{text: "DELIMITER", ok: false},
+		{text: "DELIMITER   ", ok: false},
+		{text: "DELIMITERS $$", ok: false},
+		{text: "SELECT 1", ok: false},
+	}
+
+	for _, tt := range tests {
+		delimiter, ok := delimiterCommand(tt.text)
+		if delimiter != tt.delimiter || ok != tt.ok {
+			t.Errorf("delimiterCommand(%q) = %q, %v, want %q, %v", tt.text, delimiter, ok, tt.delimiter, tt.ok)
+		}
+	}
+}
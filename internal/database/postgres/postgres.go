@@ -0,0 +1,505 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	nurl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/database"
+	"github.com/lib/pq"
+)
+
+func init() {
+	db := &Postgres{}
+	database.Register("postgres", db)
+	database.Register("postgresql", db)
+}
+
+var DefaultMigrationsTable = "schema_migrations"
+var DefaultMigrationsNameColumn = "name"
+
+var lockPollInterval = 250 * time.Millisecond
+
+var (
+	ErrNilConfig      = fmt.Errorf("no config")
+	ErrNoDatabaseName = fmt.Errorf("no database name")
+	ErrNoSchema       = fmt.Errorf("no schema")
+)
+
+// historyColumns declares the history columns with PostgreSQL types.
+var historyColumns = database.HistoryColumnTypes("TIMESTAMPTZ", "BOOLEAN")
+
+type Config struct {
+	MigrationsTable      string
+	MigrationsNameColumn string
+	DatabaseName         string
+	SchemaName           string
+}
+
+type Postgres struct {
+	conn *sql.Conn
+	db   *sql.DB
+
+	config *Config
+}
+
+func WithInstance(ctx context.Context, instance *sql.DB, config *Config) (database.Driver, error) {
+	if config == nil {
+		return nil, ErrNilConfig
+	}
+
+	if err := instance.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if config.DatabaseName == "" {
+		query := `SELECT CURRENT_DATABASE()`
+		var databaseName string
+		if err := instance.QueryRowContext(ctx, query).Scan(&databaseName); err != nil {
+			return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+
+		if len(databaseName) == 0 {
+			return nil, ErrNoDatabaseName
+		}
+
+		config.DatabaseName = databaseName
+	}
+
+	if config.SchemaName == "" {
+		query := `SELECT CURRENT_SCHEMA()`
+		var schemaName sql.NullString
+		if err := instance.QueryRowContext(ctx, query).Scan(&schemaName); err != nil {
+			return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+
+		if len(schemaName.String) == 0 {
+			return nil, ErrNoSchema
+		}
+
+		config.SchemaName = schemaName.String
+	}
+
+	if len(config.MigrationsTable) == 0 {
+		config.MigrationsTable = DefaultMigrationsTable
+	}
+
+	if len(config.MigrationsNameColumn) == 0 {
+		config.MigrationsNameColumn = DefaultMigrationsNameColumn
+	}
+
+	conn, err := instance.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Postgres{
+		conn:   conn,
+		db:     instance,
+		config: config,
+	}
+
+	return p, nil
+}
+
+func (p *Postgres) Url(conf *database.ConnectionParams) *nurl.URL {
+	user := conf.User
+	password := conf.Password
+	hostname := conf.Hostname
+	port := conf.Port
+	database := conf.Database
+	tableName := conf.MigrationsTable
+	nameColumn := conf.MigrationsNameColumn
+
+	if user == "" {
+		user = "postgres"
+	}
+	if port == 0 {
+		port = 5432
+	}
+	if database == "" {
+		database = "postgres"
+	}
+
+	query := nurl.Values{}
+	query.Add("sslmode", "disable")
+	query.Add("x-migrations-table", tableName)
+	query.Add("x-name-column", nameColumn)
+
+	return &nurl.URL{
+		Scheme:   "postgres",
+		User:     nurl.UserPassword(user, password),
+		Host:     fmt.Sprintf("%s:%d", hostname, port),
+		Path:     "/" + database,
+		RawQuery: query.Encode(),
+	}
+}
+
+func (p *Postgres) Open(ctx context.Context, url string) (database.Driver, error) {
+	purl, err := nurl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationsTable := purl.Query().Get("x-migrations-table")
+	nameColumn := purl.Query().Get("x-name-column")
+
+	filteredUrl := database.RemoveCustomQuery(purl)
+
+	db, err := sql.Open("postgres", filteredUrl.String())
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := WithInstance(ctx, db, &Config{
+		DatabaseName:         strings.TrimPrefix(purl.Path, "/"),
+		MigrationsTable:      migrationsTable,
+		MigrationsNameColumn: nameColumn,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return driver, nil
+}
+
+func (p *Postgres) Close() error {
+	connErr := p.conn.Close()
+	dbErr := p.db.Close()
+	if connErr != nil || dbErr != nil {
+		return fmt.Errorf("conn: %v, db: %v", connErr, dbErr)
+	}
+	return nil
+}
+
+// Run executes a migration outside of a transaction, which only happens for
+// migrations marked with the no-transaction directive. Statements are sent one
+// at a time because Postgres wraps a multi-statement query in an implicit
+// transaction, and commands like CREATE INDEX CONCURRENTLY refuse to run in one.
+func (p *Postgres) Run(ctx context.Context, migration string) error {
+	for _, stmt := range splitStatements(migration) {
+		if err := p.run(ctx, p.conn, stmt.Query); err != nil {
+			if dbErr, ok := err.(database.Error); ok && dbErr.Line > 0 {
+				dbErr.Line += stmt.Line - 1
+				return dbErr
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Postgres) run(ctx context.Context, ex database.Execer, migration string) error {
+	if _, err := ex.ExecContext(ctx, migration); err != nil {
+		if pgErr, ok := err.(*pq.Error); ok {
+			message := fmt.Sprintf("migration failed: %s (SQLSTATE %s)", pgErr.Message, pgErr.Code)
+			if pgErr.Detail != "" {
+				message = fmt.Sprintf("%s, %s", message, pgErr.Detail)
+			}
+			return database.Error{OrigErr: err, Err: message, Query: []byte(migration), Line: errorLine(migration, pgErr.Position)}
+		}
+		return database.Error{OrigErr: err, Err: "migration failed", Query: []byte(migration)}
+	}
+
+	return nil
+}
+
+func (p *Postgres) AppliedMigrations(ctx context.Context) ([]database.AppliedMigration, error) {
+	rows, err := p.conn.QueryContext(
+		ctx,
+		`SELECT `+p.quotedNameColumn()+`, `+database.HistoryColumns+` FROM `+p.quotedTable()+` ORDER BY `+p.quotedNameColumn()+`;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.ScanAppliedMigrations(rows)
+}
+
+func (p *Postgres) AppliedNames(ctx context.Context) ([]string, error) {
+	rows, err := p.conn.QueryContext(ctx, `SELECT `+p.quotedNameColumn()+` FROM `+p.quotedTable()+` ORDER BY `+p.quotedNameColumn()+`;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.ScanAppliedNames(rows)
+}
+
+func (p *Postgres) MarkAsApplied(ctx context.Context, migration database.AppliedMigration) error {
+	return p.markAsApplied(ctx, p.conn, migration)
+}
+
+func (p *Postgres) markAsApplied(ctx context.Context, ex database.Execer, migration database.AppliedMigration) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`INSERT INTO `+p.quotedTable()+` (`+p.quotedNameColumn()+`, `+database.HistoryColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`,
+		append([]any{migration.Name}, migration.HistoryValues()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to mark migration as applied")
+	}
+
+	return nil
+}
+
+func (p *Postgres) RemoveApplied(ctx context.Context, migration string) error {
+	return p.removeApplied(ctx, p.conn, migration)
+}
+
+func (p *Postgres) removeApplied(ctx context.Context, ex database.Execer, migration string) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`DELETE FROM `+p.quotedTable()+` WHERE `+p.quotedNameColumn()+` = $1;`,
+		migration)
+	if err != nil {
+		return fmt.Errorf("failed to remove applied migration")
+	}
+
+	return nil
+}
+
+func (p *Postgres) EnsureUnmarkedTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS ` + p.quotedUnmarkedTable() + `
+		(
+				` + p.quotedNameColumn() + ` VARCHAR(255) NOT NULL,
+				unmarked_at TIMESTAMPTZ NOT NULL,
+				tool_version VARCHAR(64),
+				unmarked_by VARCHAR(255),
+				hostname VARCHAR(255),
+				reason VARCHAR(1000)
+		);`
+
+	if _, err := p.conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return nil
+}
+
+func (p *Postgres) recordUnmarked(ctx context.Context, ex database.Execer, unmarked database.Unmarked) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`INSERT INTO `+p.quotedUnmarkedTable()+` (`+p.quotedNameColumn()+`, `+database.UnmarkedColumns+`) VALUES ($1, $2, $3, $4, $5, $6);`,
+		append([]any{unmarked.Name}, unmarked.UnmarkedValues()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to record unmarked migration")
+	}
+
+	return nil
+}
+
+func (p *Postgres) UpdateChecksum(ctx context.Context, migration string, checksum string) error {
+	_, err := p.conn.ExecContext(
+		ctx,
+		`UPDATE `+p.quotedTable()+` SET checksum = $1 WHERE `+p.quotedNameColumn()+` = $2;`,
+		checksum, migration)
+	if err != nil {
+		return fmt.Errorf("failed to update migration checksum")
+	}
+
+	return nil
+}
+
+func (p *Postgres) SetDirty(ctx context.Context, migration string, dirty bool) error {
+	_, err := p.conn.ExecContext(
+		ctx,
+		`UPDATE `+p.quotedTable()+` SET dirty = $1 WHERE `+p.quotedNameColumn()+` = $2;`,
+		dirty, migration)
+	if err != nil {
+		return fmt.Errorf("failed to update migration dirty mark")
+	}
+
+	return nil
+}
+
+func (p *Postgres) UpdateApplied(ctx context.Context, migration database.AppliedMigration) error {
+	_, err := p.conn.ExecContext(
+		ctx,
+		`UPDATE `+p.quotedTable()+` SET `+database.HistoryAssignments(func(i int) string { return "$" + strconv.Itoa(i) })+` WHERE `+p.quotedNameColumn()+` = $9;`,
+		append(migration.HistoryValues(), migration.Name)...)
+	if err != nil {
+		return fmt.Errorf("failed to update applied migration")
+	}
+
+	return nil
+}
+
+func (p *Postgres) Query(ctx context.Context, query string) (*sql.Rows, error) {
+	return p.conn.QueryContext(ctx, query)
+}
+
+func (p *Postgres) Begin(ctx context.Context) (database.Tx, error) {
+	tx, err := p.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &postgresTx{tx: tx, p: p}, nil
+}
+
+func (p *Postgres) Transactional() bool {
+	return true
+}
+
+// Lock takes a session level advisory lock, polling pg_try_advisory_lock so
+// the wait can be bounded by timeout.
+func (p *Postgres) Lock(ctx context.Context, timeout time.Duration) error {
+	query := `SELECT pg_try_advisory_lock($1);`
+	deadline := time.Now().Add(timeout)
+
+	for {
+		var locked bool
+		if err := p.conn.QueryRowContext(ctx, query, p.lockKey()).Scan(&locked); err != nil {
+			return &database.Error{OrigErr: err, Err: "failed to take migrations lock", Query: []byte(query)}
+		}
+
+		if locked {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w (waited %s for advisory lock %d)", database.ErrLocked, timeout, p.lockKey())
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (p *Postgres) Unlock(ctx context.Context) error {
+	query := `SELECT pg_advisory_unlock($1);`
+	if _, err := p.conn.ExecContext(ctx, query, p.lockKey()); err != nil {
+		return &database.Error{OrigErr: err, Err: "failed to release migrations lock", Query: []byte(query)}
+	}
+
+	return nil
+}
+
+// lockKey derives the advisory lock key from the migrations table, so
+// projects sharing a database but not a migrations table do not block each
+// other.
+func (p *Postgres) lockKey() int64 {
+	name := "gomigrate:" + p.config.DatabaseName + "." + p.config.SchemaName + "." + p.config.MigrationsTable
+	return int64(crc32.ChecksumIEEE([]byte(name)))
+}
+
+func (p *Postgres) EnsureTable(ctx context.Context) error {
+	return p.ensureMigrationsTable(ctx)
+}
+
+func (p *Postgres) CheckTable(ctx context.Context) (database.TableState, error) {
+	query := `SELECT column_name FROM information_schema.columns WHERE table_schema = $1 AND table_name = $2;`
+
+	rows, err := p.conn.QueryContext(ctx, query, p.config.SchemaName, p.config.MigrationsTable)
+	if err != nil {
+		return database.TableMissing, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return database.ScanTableState(rows)
+}
+
+func (p *Postgres) ensureMigrationsTable(ctx context.Context) error {
+	query := p.createTableQuery()
+
+	if _, err := p.conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return p.ensureHistoryColumns(ctx)
+}
+
+func (p *Postgres) createTableQuery() string {
+	return `CREATE TABLE IF NOT EXISTS ` + p.quotedTable() + `
+		(
+				` + p.quotedNameColumn() + ` VARCHAR(255) NOT NULL PRIMARY KEY
+		);`
+}
+
+func (p *Postgres) ensureHistoryColumns(ctx context.Context) error {
+	for _, column := range historyColumns {
+		query := `ALTER TABLE ` + p.quotedTable() + ` ADD COLUMN IF NOT EXISTS ` + column.Name + ` ` + column.Type + `;`
+
+		if _, err := p.conn.ExecContext(ctx, query); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+	}
+
+	return nil
+}
+
+func (p *Postgres) quotedTable() string {
+	if p.config.SchemaName == "" {
+		return pq.QuoteIdentifier(p.config.MigrationsTable)
+	}
+	return pq.QuoteIdentifier(p.config.SchemaName) + "." + pq.QuoteIdentifier(p.config.MigrationsTable)
+}
+
+func (p *Postgres) quotedUnmarkedTable() string {
+	table := pq.QuoteIdentifier(p.config.MigrationsTable + "_unmarked")
+	if p.config.SchemaName == "" {
+		return table
+	}
+	return pq.QuoteIdentifier(p.config.SchemaName) + "." + table
+}
+
+func (p *Postgres) quotedNameColumn() string {
+	return pq.QuoteIdentifier(p.config.MigrationsNameColumn)
+}
+
+// errorLine converts the 1-based character position reported by the server
+// into the line of the query it points at.
+func errorLine(query string, position string) uint {
+	pos, err := strconv.Atoi(position)
+	if err != nil || pos <= 0 {
+		return 0
+	}
+
+	runes := []rune(query)
+	if pos > len(runes) {
+		pos = len(runes)
+	}
+
+	return uint(strings.Count(string(runes[:pos-1]), "\n")) + 1
+}
+
+type postgresTx struct {
+	tx *sql.Tx
+	p  *Postgres
+}
+
+func (t *postgresTx) Run(ctx context.Context, migration string) error {
+	return t.p.run(ctx, t.tx, migration)
+}
+
+func (t *postgresTx) RunFunc(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return fn(ctx, t.tx)
+}
+
+func (t *postgresTx) MarkAsApplied(ctx context.Context, migration database.AppliedMigration) error {
+	return t.p.markAsApplied(ctx, t.tx, migration)
+}
+
+func (t *postgresTx) RemoveApplied(ctx context.Context, migration string) error {
+	return t.p.removeApplied(ctx, t.tx, migration)
+}
+
+func (t *postgresTx) RecordUnmarked(ctx context.Context, unmarked database.Unmarked) error {
+	return t.p.recordUnmarked(ctx, t.tx, unmarked)
+}
+
+func (t *postgresTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *postgresTx) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import "testing"
+
+func TestErrorLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		position string
+		want     uint
+	}{
+		{name: "first line", query: "SELEC 1", position: "1", want: 1},
+		{name: "later line", query: "CREATE TABLE a (\n\tid int,\n\tname txt\n)", position: "30", want: 3},
+		{name: "multibyte characters", query: "SELECT 'é',\n'ü',\nfoo", position: "18", want: 3},
+		{name: "past the end", query: "SELECT 1\nFROM", position: "100", want: 2},
+		{name: "no position", query: "SELECT 1", position: "", want: 0},
+		{name: "invalid position", query: "SELECT 1", position: "x", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorLine(tt.query, tt.position); got != tt.want {
+				t.Errorf("errorLine(%q, %q) = %d, want %d", tt.query, tt.position, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStatementErrorLine checks the file line Run reports, a statement line
+// plus the line of the error inside it, for statements after comments.
+func TestStatementErrorLine(t *testing.T) {
+	body := "SELECT 1;\n\n-- a comment\n-- and another\nCREATE TABLE a (\n\tid int,\n\tname txt\n);"
+
+	statements := splitStatements(body)
+	if len(statements) != 2 {
+		t.Fatalf("got %d statements, want 2", len(statements))
+	}
+
+	stmt := statements[1]
+	line := stmt.Line + errorLine(stmt.Query, "30") - 1
+	if line != 7 {
+		t.Errorf("error reported on line %d, want 7", line)
+	}
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+// NewScripter returns a Scripter that leaves the migrations table unqualified,
+// so scripts use the search_path of the session that runs them.
+func (p *Postgres) NewScripter(url string) (database.Scripter, error) {
+	table, nameColumn, err := database.ScriptTable(url, DefaultMigrationsTable, DefaultMigrationsNameColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Postgres{config: &Config{MigrationsTable: table, MigrationsNameColumn: nameColumn}}, nil
+}
+
+func (p *Postgres) EnsureTableScript() string {
+	var script strings.Builder
+	script.WriteString(p.createTableQuery())
+	script.WriteString("\n")
+
+	for _, column := range historyColumns {
+		script.WriteString(`ALTER TABLE ` + p.quotedTable() + ` ADD COLUMN IF NOT EXISTS ` + column.Name + ` ` + column.Type + ";\n")
+	}
+
+	return script.String()
+}
+
+func (p *Postgres) MarkAsAppliedScript(migration database.AppliedMigration) string {
+	return p.markAsAppliedStatement(migration) + "\n"
+}
+
+func (p *Postgres) RemoveAppliedScript(migration string) string {
+	return `DELETE FROM ` + p.quotedTable() + ` WHERE ` + p.quotedNameColumn() + ` = ` + database.QuoteLiteral(migration) + `;` + "\n"
+}
+
+// GuardedScript wraps the migration in a DO block. Each statement goes
+// through EXECUTE, so statements that PL/pgSQL handles differently, like a
+// SELECT without INTO, behave as they would at the top level.
+func (p *Postgres) GuardedScript(migration database.AppliedMigration, body string) (string, error) {
+	var script strings.Builder
+	script.WriteString("DO $gomigrate$\nBEGIN\n")
+	script.WriteString("\tIF NOT EXISTS (SELECT 1 FROM " + p.quotedTable() + " WHERE " + p.quotedNameColumn() + " = " + database.QuoteLiteral(migration.Name) + ") THEN\n")
+
+	for _, stmt := range splitStatements(body) {
+		script.WriteString("\t\tEXECUTE " + database.QuoteLiteral(stmt.Query) + ";\n")
+	}
+
+	script.WriteString("\t\t" + p.markAsAppliedStatement(migration) + "\n")
+	script.WriteString("\tEND IF;\nEND\n$gomigrate$;\n")
+
+	return script.String(), nil
+}
+
+func (p *Postgres) markAsAppliedStatement(migration database.AppliedMigration) string {
+	return `INSERT INTO ` + p.quotedTable() + ` (` + p.quotedNameColumn() + `, ` + database.HistoryColumns + `) VALUES (` +
+		database.QuoteLiteral(migration.Name) + `, ` + migration.HistoryLiterals("NOW()", database.QuoteLiteral) + `);`
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"regexp"
+	"strings"
+)
+
+// dollarTagPattern matches the opening of a dollar quoted string, like $$ or
+// $body$.
+var dollarTagPattern = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// statement is a single query taken from a migration body, along with the
+// line of the body it starts on. The query starts at its first token, without
+// the comments before it, so server positions count from that line.
+type statement struct {
+	Query string
+	Line  uint
+}
+
+// splitStatements breaks a migration body on semicolons that are not inside
+// quotes, dollar quoted strings or comments.
+func splitStatements(body string) []statement {
+	statements := []statement{}
+	line := uint(1)
+
+	var current strings.Builder
+	startLine := uint(0)
+
+	flush := func() {
+		if startLine != 0 {
+			statements = append(statements, statement{Query: strings.TrimSpace(current.String()), Line: startLine})
+		}
+		current.Reset()
+		startLine = 0
+	}
+
+	for i := 0; i < len(body); {
+		c := body[i]
+		end := i + 1
+		comment := false
+
+		switch {
+		case c == '\'' || c == '"':
+			end = closingQuote(body, i)
+
+		case c == '$' && dollarTagPattern.MatchString(body[i:]):
+			tag := dollarTagPattern.FindString(body[i:])
+			closing := strings.Index(body[i+len(tag):], tag)
+			if closing < 0 {
+				end = len(body)
+			} else {
+				end = i + len(tag) + closing + len(tag)
+			}
+
+		case strings.HasPrefix(body[i:], "--"):
+			comment = true
+			end = strings.IndexByte(body[i:], '\n')
+			if end < 0 {
+				end = len(body)
+			} else {
+				end += i
+			}
+
+		case strings.HasPrefix(body[i:], "/*"):
+			comment = true
+			end = closingComment(body, i)
+
+		case c == ';':
+			flush()
+			i++
+			continue
+		}
+
+		text := body[i:end]
+		if startLine == 0 && !comment && strings.TrimSpace(text) != "" {
+			startLine = line
+		}
+		line += uint(strings.Count(text, "\n"))
+		if startLine != 0 {
+			current.WriteString(text)
+		}
+		i = end
+	}
+
+	flush()
+
+	return statements
+}
+
+// closingQuote returns the index just past the quote that closes the one
+// opened at start. Doubled quotes are part of the string.
+func closingQuote(body string, start int) int {
+	quote := body[start]
+	for i := start + 1; i < len(body); i++ {
+		if body[i] == quote {
+			if i+1 < len(body) && body[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(body)
+}
+
+// closingComment returns the index just past the end of the block comment
+// opened at start. Postgres block comments nest.
+func closingComment(body string, start int) int {
+	depth := 0
+	for i := start; i < len(body)-1; i++ {
+		switch body[i : i+2] {
+		case "/*":
+			depth++
+			i++
+		case "*/":
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(body)
+}
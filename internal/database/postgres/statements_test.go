@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []statement
+	}{
+		{
+			name: "empty",
+			body: "\n  \n",
+			want: []statement{},
+		},
+		{
+			name: "one statement per line",
+			body: "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want: []statement{
+				{Query: "CREATE TABLE a (id int)", Line: 1},
+				{Query: "CREATE TABLE b (id int)", Line: 2},
+			},
+		},
+		{
+			name: "last statement without semicolon",
+			body: "SELECT 1;\nSELECT 2\n",
+			want: []statement{
+				{Query: "SELECT 1", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name: "empty statements",
+			body: ";;\nSELECT 1;;\n",
+			want: []statement{
+				{Query: "SELECT 1", Line: 2},
+			},
+		},
+		{
+			name: "statement spanning lines",
+			body: "\n\nCREATE TABLE a (\n\tid int\n);\nSELECT 1;",
+			want: []statement{
+				{Query: "CREATE TABLE a (\n\tid int\n)", Line: 3},
+				{Query: "SELECT 1", Line: 6},
+			},
+		},
+		{
+			name: "semicolon in string",
+			body: "INSERT INTO a VALUES ('x;y', 'it''s;');\nSELECT 1;",
+			want: []statement{
+				{Query: "INSERT INTO a VALUES ('x;y', 'it''s;')", Line: 1},
+				{Query: "SELECT 1", Line: 2},
+			},
+		},
+		{
+			name: "semicolon in quoted identifier",
+			body: `CREATE TABLE "a;b" (id int);`,
+			want: []statement{
+				{Query: `CREATE TABLE "a;b" (id int)`, Line: 1},
+			},
+		},
+		{
+			name: "dollar quoted function body",
+			body: "CREATE FUNCTION f() RETURNS int AS $$\n\tSELECT 1;\n$$ LANGUAGE sql;\nSELECT f();",
+			want: []statement{
+				{Query: "CREATE FUNCTION f() RETURNS int AS $$\n\tSELECT 1;\n$$ LANGUAGE sql", Line: 1},
+				{Query: "SELECT f()", Line: 4},
+			},
+		},
+		{
+			name: "tagged dollar quote containing $$",
+			body: "DO $body$ BEGIN RAISE NOTICE '$$;'; END $body$;\nSELECT 1;",
+			want: []statement{
+				{Query: "DO $body$ BEGIN RAISE NOTICE '$$;'; END $body$", Line: 1},
+				{Query: "SELECT 1", Line: 2},
+			},
+		},
+		{
+			name: "positional parameter is not a dollar quote",
+			body: "PREPARE p AS SELECT $1;\nSELECT 2;",
+			want: []statement{
+				{Query: "PREPARE p AS SELECT $1", Line: 1},
+				{Query: "SELECT 2", Line: 2},
+			},
+		},
+		{
+			name: "comment lines before a statement",
+			body: "-- first\n-- second; still a comment\nCREATE TABLE a (id int);",
+			want: []statement{
+				{Query: "CREATE TABLE a (id int)", Line: 3},
+			},
+		},
+		{
+			name: "comments between statements",
+			body: "SELECT 1;\n\n-- the next one\n/* spans\n   lines; */\nSELECT 2;",
+			want: []statement{
+				{Query: "SELECT 1", Line: 1},
+				{Query: "SELECT 2", Line: 6},
+			},
+		},
+		{
+			name: "nested block comment",
+			body: "/* outer /* inner; */ still outer; */\nSELECT 1;",
+			want: []statement{
+				{Query: "SELECT 1", Line: 2},
+			},
+		},
+		{
+			name: "comment inside a statement",
+			body: "SELECT 1 -- one;\n, 2;",
+			want: []statement{
+				{Query: "SELECT 1 -- one;\n, 2", Line: 1},
+			},
+		},
+		{
+			name: "only comments",
+			body: "-- nothing to run;\n/* at all; */\n",
+			want: []statement{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q)\n got %#v\nwant %#v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
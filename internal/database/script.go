@@ -0,0 +1,74 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrScriptNotSupported is returned by Scripter.GuardedScript for engines
+// that cannot run statements conditionally.
+var ErrScriptNotSupported = errors.New("database driver: idempotent scripts are not supported")
+
+// Scripter renders the SQL a driver would run, for scripts that are reviewed
+// and run by hand.
+type Scripter interface {
+	// EnsureTableScript creates the migrations table, or adds the columns it
+	// lacks, when run against a database in any state.
+	EnsureTableScript() string
+
+	// MarkAsAppliedScript and RemoveAppliedScript render the statements that
+	// MarkAsApplied and RemoveApplied run, with the values inlined, to be
+	// appended after a migration body.
+	MarkAsAppliedScript(migration AppliedMigration) string
+
+	RemoveAppliedScript(migration string) string
+
+	// GuardedScript wraps a migration body and its bookkeeping so they only
+	// run when the migration is not in the migrations table yet, making the
+	// script safe to run more than once.
+	GuardedScript(migration AppliedMigration, body string) (string, error)
+}
+
+// NewScripter returns the Scripter of the driver named by the url scheme.
+// Unlike Open, it never connects to the database.
+func NewScripter(rawUrl string) (Scripter, error) {
+	provider, _, ok := strings.Cut(rawUrl, "://")
+	if !ok {
+		return nil, fmt.Errorf("database driver: missing driver in url")
+	}
+
+	driversMu.RLock()
+	d, ok := drivers[provider]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("database driver: unknown driver %v", provider)
+	}
+
+	return d.NewScripter(rawUrl)
+}
+
+// ScriptTable reads the migrations table and name column set in the query of
+// rawUrl with x-migrations-table and x-name-column, falling back to the
+// defaults given, for the NewScripter of drivers. Only the query is parsed, as
+// the rest of the url is never connected to.
+func ScriptTable(rawUrl string, defaultTable string, defaultNameColumn string) (string, string, error) {
+	_, rawQuery, _ := strings.Cut(rawUrl, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", "", err
+	}
+
+	table := query.Get("x-migrations-table")
+	if len(table) == 0 {
+		table = defaultTable
+	}
+
+	nameColumn := query.Get("x-name-column")
+	if len(nameColumn) == 0 {
+		nameColumn = defaultNameColumn
+	}
+
+	return table, nameColumn, nil
+}
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+func (s *SQLite) NewScripter(url string) (database.Scripter, error) {
+	table, nameColumn, err := database.ScriptTable(url, DefaultMigrationsTable, DefaultMigrationsNameColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLite{config: &Config{MigrationsTable: table, MigrationsNameColumn: nameColumn}}, nil
+}
+
+// EnsureTableScript creates the migrations table with every column. SQLite
+// has no conditional ALTER TABLE, so tables from older versions are upgraded
+// by the driver, or by UpgradeTableScript in dry runs.
+func (s *SQLite) EnsureTableScript() string {
+	columns := []string{s.quotedNameColumn() + " VARCHAR(255) NOT NULL PRIMARY KEY"}
+	for _, column := range historyColumns {
+		columns = append(columns, column.Name+" "+column.Type)
+	}
+
+	return "CREATE TABLE IF NOT EXISTS " + s.quotedTable() + " (\n\t" + strings.Join(columns, ",\n\t") + "\n);\n"
+}
+
+// UpgradeTableScript adds the history columns the migrations table lacks. The
+// script is only valid for the database it was read from.
+func (s *SQLite) UpgradeTableScript(ctx context.Context) (string, error) {
+	queries, err := s.addHistoryColumnQueries(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var script strings.Builder
+	for _, query := range queries {
+		script.WriteString(query + "\n")
+	}
+
+	return script.String(), nil
+}
+
+func (s *SQLite) MarkAsAppliedScript(migration database.AppliedMigration) string {
+	return `INSERT INTO ` + s.quotedTable() + ` (` + s.quotedNameColumn() + `, ` + database.HistoryColumns + `) VALUES (` +
+		database.QuoteLiteral(migration.Name) + `, ` + migration.HistoryLiterals("CURRENT_TIMESTAMP", database.QuoteLiteral) + `);` + "\n"
+}
+
+func (s *SQLite) RemoveAppliedScript(migration string) string {
+	return `DELETE FROM ` + s.quotedTable() + ` WHERE ` + s.quotedNameColumn() + ` = ` + database.QuoteLiteral(migration) + `;` + "\n"
+}
+
+// GuardedScript is not supported, SQLite cannot run statements conditionally.
+func (s *SQLite) GuardedScript(migration database.AppliedMigration, body string) (string, error) {
+	return "", database.ErrScriptNotSupported
+}
@@ -0,0 +1,564 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	nurl "net/url"
+	"strings"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/database"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func init() {
+	database.Register("sqlite", &SQLite{})
+}
+
+var DefaultMigrationsTable = "schema_migrations"
+var DefaultMigrationsNameColumn = "name"
+var DefaultDatabaseFile = "gomigrate.db"
+
+var lockPollInterval = 250 * time.Millisecond
+
+// DefaultBusyTimeout is how long a statement waits for another process
+// writing to the database, unless the url sets a busy timeout itself.
+var DefaultBusyTimeout = 15 * time.Second
+
+var (
+	ErrNilConfig      = fmt.Errorf("no config")
+	ErrNoDatabaseFile = fmt.Errorf("no database file")
+)
+
+// historyColumns declares the history columns with SQLite types.
+var historyColumns = database.HistoryColumnTypes("DATETIME", "BOOLEAN")
+
+type Config struct {
+	MigrationsTable      string
+	MigrationsNameColumn string
+	DatabaseFile         string
+}
+
+type SQLite struct {
+	conn *sql.Conn
+	db   *sql.DB
+
+	config *Config
+}
+
+func WithInstance(ctx context.Context, instance *sql.DB, config *Config) (database.Driver, error) {
+	if config == nil {
+		return nil, ErrNilConfig
+	}
+
+	if err := instance.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(config.MigrationsTable) == 0 {
+		config.MigrationsTable = DefaultMigrationsTable
+	}
+
+	if len(config.MigrationsNameColumn) == 0 {
+		config.MigrationsNameColumn = DefaultMigrationsNameColumn
+	}
+
+	// Every connection to an in-memory database sees its own empty database,
+	// so all the work has to go through a single connection.
+	conn, err := instance.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SQLite{
+		conn:   conn,
+		db:     instance,
+		config: config,
+	}
+
+	busyTimeout, err := s.busyTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if busyTimeout == 0 {
+		if err := s.setBusyTimeout(ctx, DefaultBusyTimeout); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *SQLite) Url(conf *database.ConnectionParams) *nurl.URL {
+	file := conf.Database
+	tableName := conf.MigrationsTable
+	nameColumn := conf.MigrationsNameColumn
+
+	if file == "" {
+		file = DefaultDatabaseFile
+	}
+
+	query := nurl.Values{}
+	query.Add("x-migrations-table", tableName)
+	query.Add("x-name-column", nameColumn)
+
+	return &nurl.URL{
+		Scheme:   "sqlite",
+		Path:     file,
+		RawQuery: query.Encode(),
+	}
+}
+
+// Open accepts urls in the form sqlite://<file>[?params]. The file is taken
+// verbatim, so both relative paths and ":memory:" work, and absolute paths are
+// written as sqlite:///path/to/file.db.
+func (s *SQLite) Open(ctx context.Context, url string) (database.Driver, error) {
+	file, rawQuery, _ := strings.Cut(strings.TrimPrefix(url, "sqlite://"), "?")
+	if file == "" {
+		return nil, ErrNoDatabaseFile
+	}
+
+	query, err := nurl.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationsTable := query.Get("x-migrations-table")
+	nameColumn := query.Get("x-name-column")
+
+	filteredUrl := database.RemoveCustomQuery(&nurl.URL{RawQuery: rawQuery})
+
+	dsn := file
+	if filteredUrl.RawQuery != "" {
+		dsn = dsn + "?" + filteredUrl.RawQuery
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := WithInstance(ctx, db, &Config{
+		DatabaseFile:         file,
+		MigrationsTable:      migrationsTable,
+		MigrationsNameColumn: nameColumn,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return driver, nil
+}
+
+func (s *SQLite) Close() error {
+	connErr := s.conn.Close()
+	dbErr := s.db.Close()
+	if connErr != nil || dbErr != nil {
+		return fmt.Errorf("conn: %v, db: %v", connErr, dbErr)
+	}
+	return nil
+}
+
+func (s *SQLite) Run(ctx context.Context, migration string) error {
+	return s.run(ctx, s.conn, migration)
+}
+
+func (s *SQLite) run(ctx context.Context, ex database.Execer, migration string) error {
+	if _, err := ex.ExecContext(ctx, migration); err != nil {
+		if liteErr, ok := err.(*sqlite.Error); ok {
+			message := fmt.Sprintf("migration failed: %s (code %d)", liteErr.Error(), liteErr.Code())
+			return database.Error{OrigErr: err, Err: message, Query: []byte(migration)}
+		}
+		return database.Error{OrigErr: err, Err: "migration failed", Query: []byte(migration)}
+	}
+
+	return nil
+}
+
+func (s *SQLite) AppliedMigrations(ctx context.Context) ([]database.AppliedMigration, error) {
+	rows, err := s.conn.QueryContext(
+		ctx,
+		`SELECT `+s.quotedNameColumn()+`, `+database.HistoryColumns+` FROM `+s.quotedTable()+` ORDER BY `+s.quotedNameColumn()+`;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.ScanAppliedMigrations(rows)
+}
+
+func (s *SQLite) AppliedNames(ctx context.Context) ([]string, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT `+s.quotedNameColumn()+` FROM `+s.quotedTable()+` ORDER BY `+s.quotedNameColumn()+`;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.ScanAppliedNames(rows)
+}
+
+func (s *SQLite) MarkAsApplied(ctx context.Context, migration database.AppliedMigration) error {
+	return s.markAsApplied(ctx, s.conn, migration)
+}
+
+func (s *SQLite) markAsApplied(ctx context.Context, ex database.Execer, migration database.AppliedMigration) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`INSERT INTO `+s.quotedTable()+` (`+s.quotedNameColumn()+`, `+database.HistoryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		append([]any{migration.Name}, migration.HistoryValues()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to mark migration as applied")
+	}
+
+	return nil
+}
+
+func (s *SQLite) RemoveApplied(ctx context.Context, migration string) error {
+	return s.removeApplied(ctx, s.conn, migration)
+}
+
+func (s *SQLite) removeApplied(ctx context.Context, ex database.Execer, migration string) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`DELETE FROM `+s.quotedTable()+` WHERE `+s.quotedNameColumn()+` = ?;`,
+		migration)
+	if err != nil {
+		return fmt.Errorf("failed to remove applied migration")
+	}
+
+	return nil
+}
+
+func (s *SQLite) EnsureUnmarkedTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS ` + s.quotedUnmarkedTable() + `
+		(
+				` + s.quotedNameColumn() + ` VARCHAR(255) NOT NULL,
+				unmarked_at DATETIME NOT NULL,
+				tool_version VARCHAR(64) NULL,
+				unmarked_by VARCHAR(255) NULL,
+				hostname VARCHAR(255) NULL,
+				reason VARCHAR(1000) NULL
+		);`
+
+	if _, err := s.conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return nil
+}
+
+func (s *SQLite) recordUnmarked(ctx context.Context, ex database.Execer, unmarked database.Unmarked) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`INSERT INTO `+s.quotedUnmarkedTable()+` (`+s.quotedNameColumn()+`, `+database.UnmarkedColumns+`) VALUES (?, ?, ?, ?, ?, ?);`,
+		append([]any{unmarked.Name}, unmarked.UnmarkedValues()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to record unmarked migration")
+	}
+
+	return nil
+}
+
+func (s *SQLite) UpdateChecksum(ctx context.Context, migration string, checksum string) error {
+	_, err := s.conn.ExecContext(
+		ctx,
+		`UPDATE `+s.quotedTable()+` SET checksum = ? WHERE `+s.quotedNameColumn()+` = ?;`,
+		checksum, migration)
+	if err != nil {
+		return fmt.Errorf("failed to update migration checksum")
+	}
+
+	return nil
+}
+
+func (s *SQLite) SetDirty(ctx context.Context, migration string, dirty bool) error {
+	_, err := s.conn.ExecContext(
+		ctx,
+		`UPDATE `+s.quotedTable()+` SET dirty = ? WHERE `+s.quotedNameColumn()+` = ?;`,
+		dirty, migration)
+	if err != nil {
+		return fmt.Errorf("failed to update migration dirty mark")
+	}
+
+	return nil
+}
+
+func (s *SQLite) UpdateApplied(ctx context.Context, migration database.AppliedMigration) error {
+	_, err := s.conn.ExecContext(
+		ctx,
+		`UPDATE `+s.quotedTable()+` SET `+database.HistoryAssignments(func(int) string { return "?" })+` WHERE `+s.quotedNameColumn()+` = ?;`,
+		append(migration.HistoryValues(), migration.Name)...)
+	if err != nil {
+		return fmt.Errorf("failed to update applied migration")
+	}
+
+	return nil
+}
+
+func (s *SQLite) Query(ctx context.Context, query string) (*sql.Rows, error) {
+	return s.conn.QueryContext(ctx, query)
+}
+
+func (s *SQLite) Begin(ctx context.Context) (database.Tx, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteTx{tx: tx, s: s}, nil
+}
+
+func (s *SQLite) Transactional() bool {
+	return true
+}
+
+// Lock inserts the single row of a lock table next to the migrations table.
+// SQLite has no lock that outlives a transaction, so the row stays behind when
+// a process dies while holding it, and has to be deleted by hand.
+func (s *SQLite) Lock(ctx context.Context, timeout time.Duration) error {
+	// Writes wait for other processes writing to the database, like one
+	// running a migration, at most as long as the lock may be waited for.
+	busyTimeout, err := s.busyTimeout(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.setBusyTimeout(ctx, timeout); err != nil {
+		return err
+	}
+	defer s.setBusyTimeout(context.Background(), busyTimeout)
+
+	deadline := time.Now().Add(timeout)
+	locked := func() error {
+		return fmt.Errorf("%w (waited %s for the row in %s, delete it if no other process is migrating)", database.ErrLocked, timeout, s.lockTable())
+	}
+
+	query := `CREATE TABLE IF NOT EXISTS ` + s.quotedLockTable() + ` (id INTEGER NOT NULL PRIMARY KEY CHECK (id = 1), locked_at DATETIME NOT NULL);`
+	if _, err := s.conn.ExecContext(ctx, query); err != nil {
+		if isBusy(err) {
+			return locked()
+		}
+		return &database.Error{OrigErr: err, Err: "failed to create migrations lock table", Query: []byte(query)}
+	}
+
+	query = `INSERT OR IGNORE INTO ` + s.quotedLockTable() + ` (id, locked_at) VALUES (1, CURRENT_TIMESTAMP);`
+	for {
+		result, err := s.conn.ExecContext(ctx, query)
+		if err != nil {
+			if isBusy(err) {
+				return locked()
+			}
+			return &database.Error{OrigErr: err, Err: "failed to take migrations lock", Query: []byte(query)}
+		}
+
+		if rows, err := result.RowsAffected(); err == nil && rows == 1 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return locked()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (s *SQLite) Unlock(ctx context.Context) error {
+	query := `DELETE FROM ` + s.quotedLockTable() + ` WHERE id = 1;`
+	if _, err := s.conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Err: "failed to release migrations lock", Query: []byte(query)}
+	}
+
+	return nil
+}
+
+func (s *SQLite) EnsureTable(ctx context.Context) error {
+	return s.ensureMigrationsTable(ctx)
+}
+
+func (s *SQLite) CheckTable(ctx context.Context) (database.TableState, error) {
+	query := `SELECT name FROM pragma_table_info(?);`
+
+	rows, err := s.conn.QueryContext(ctx, query, s.config.MigrationsTable)
+	if err != nil {
+		return database.TableMissing, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return database.ScanTableState(rows)
+}
+
+// ensureMigrationsTable only writes to the database when the table or its
+// columns are missing, as a write has to wait for any other process writing,
+// like one running a migration while holding the lock.
+func (s *SQLite) ensureMigrationsTable(ctx context.Context) error {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`
+
+	var count int
+	if err := s.conn.QueryRowContext(ctx, query, s.config.MigrationsTable).Scan(&count); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	if count == 0 {
+		query = s.createTableQuery()
+		if _, err := s.conn.ExecContext(ctx, query); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+	}
+
+	return s.ensureHistoryColumns(ctx)
+}
+
+func (s *SQLite) createTableQuery() string {
+	return `CREATE TABLE IF NOT EXISTS ` + s.quotedTable() + `
+		(
+				` + s.quotedNameColumn() + ` VARCHAR(255) NOT NULL PRIMARY KEY
+		);`
+}
+
+func (s *SQLite) ensureHistoryColumns(ctx context.Context) error {
+	queries, err := s.addHistoryColumnQueries(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range queries {
+		if _, err := s.conn.ExecContext(ctx, query); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+	}
+
+	return nil
+}
+
+// addHistoryColumnQueries returns the statements adding the history columns
+// the migrations table lacks. It returns none when the table does not exist.
+func (s *SQLite) addHistoryColumnQueries(ctx context.Context) ([]string, error) {
+	query := `SELECT name FROM pragma_table_info(?);`
+
+	rows, err := s.conn.QueryContext(ctx, query, s.config.MigrationsTable)
+	if err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	defer rows.Close()
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		existing[strings.ToLower(name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(existing) == 0 {
+		return nil, nil
+	}
+
+	queries := []string{}
+	for _, column := range historyColumns {
+		if !existing[column.Name] {
+			queries = append(queries, `ALTER TABLE `+s.quotedTable()+` ADD COLUMN `+column.Name+` `+column.Type+`;`)
+		}
+	}
+
+	return queries, nil
+}
+
+func (s *SQLite) quotedTable() string {
+	return quoteIdentifier(s.config.MigrationsTable)
+}
+
+func (s *SQLite) busyTimeout(ctx context.Context) (time.Duration, error) {
+	query := `PRAGMA busy_timeout;`
+
+	var ms int64
+	if err := s.conn.QueryRowContext(ctx, query).Scan(&ms); err != nil {
+		return 0, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (s *SQLite) setBusyTimeout(ctx context.Context, timeout time.Duration) error {
+	query := fmt.Sprintf(`PRAGMA busy_timeout = %d;`, timeout.Milliseconds())
+	if _, err := s.conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return nil
+}
+
+// lockTable is the table Lock keeps its row in, named after the migrations
+// table so projects sharing a database file do not block each other.
+func (s *SQLite) lockTable() string {
+	return s.config.MigrationsTable + "_lock"
+}
+
+func (s *SQLite) quotedLockTable() string {
+	return quoteIdentifier(s.lockTable())
+}
+
+func (s *SQLite) quotedUnmarkedTable() string {
+	return quoteIdentifier(s.config.MigrationsTable + "_unmarked")
+}
+
+func (s *SQLite) quotedNameColumn() string {
+	return quoteIdentifier(s.config.MigrationsNameColumn)
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// isBusy reports whether err is SQLite refusing a write because another
+// connection is writing to the database.
+func isBusy(err error) bool {
+	liteErr, ok := err.(*sqlite.Error)
+	if !ok {
+		return false
+	}
+
+	code := liteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+type sqliteTx struct {
+	tx *sql.Tx
+	s  *SQLite
+}
+
+func (t *sqliteTx) Run(ctx context.Context, migration string) error {
+	return t.s.run(ctx, t.tx, migration)
+}
+
+func (t *sqliteTx) RunFunc(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return fn(ctx, t.tx)
+}
+
+func (t *sqliteTx) MarkAsApplied(ctx context.Context, migration database.AppliedMigration) error {
+	return t.s.markAsApplied(ctx, t.tx, migration)
+}
+
+func (t *sqliteTx) RemoveApplied(ctx context.Context, migration string) error {
+	return t.s.removeApplied(ctx, t.tx, migration)
+}
+
+func (t *sqliteTx) RecordUnmarked(ctx context.Context, unmarked database.Unmarked) error {
+	return t.s.recordUnmarked(ctx, t.tx, unmarked)
+}
+
+func (t *sqliteTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqliteTx) Rollback() error {
+	return t.tx.Rollback()
+}
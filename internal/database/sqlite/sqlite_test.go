@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+func openTest(t *testing.T, url string) database.Driver {
+	t.Helper()
+
+	driver, err := database.Open(context.Background(), url)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { driver.Close() })
+
+	return driver
+}
+
+func TestLock(t *testing.T) {
+	lockPollInterval = 10 * time.Millisecond
+
+	url := "sqlite://" + filepath.Join(t.TempDir(), "test.db")
+	first := openTest(t, url)
+	second := openTest(t, url)
+	ctx := context.Background()
+
+	if err := first.Lock(ctx, time.Second); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	if err := second.Lock(ctx, 50*time.Millisecond); !errors.Is(err, database.ErrLocked) {
+		t.Fatalf("Lock() while held error = %v, want ErrLocked", err)
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+
+	if err := second.Lock(ctx, time.Second); err != nil {
+		t.Fatalf("Lock() after Unlock() error = %v", err)
+	}
+	if err := second.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+}
+
+func TestLockWaits(t *testing.T) {
+	lockPollInterval = 10 * time.Millisecond
+
+	url := "sqlite://" + filepath.Join(t.TempDir(), "test.db")
+	first := openTest(t, url)
+	second := openTest(t, url)
+	ctx := context.Background()
+
+	if err := first.Lock(ctx, time.Second); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		first.Unlock(context.Background())
+	}()
+
+	if err := second.Lock(ctx, 5*time.Second); err != nil {
+		t.Fatalf("Lock() waiting for Unlock() error = %v", err)
+	}
+	second.Unlock(ctx)
+}
+
+func TestEnsureTable(t *testing.T) {
+	url := "sqlite://" + filepath.Join(t.TempDir(), "test.db") + "?x-migrations-table=history"
+	driver := openTest(t, url)
+	ctx := context.Background()
+
+	checkState := func(want database.TableState) {
+		t.Helper()
+
+		state, err := driver.CheckTable(ctx)
+		if err != nil {
+			t.Fatalf("CheckTable() error = %v", err)
+		}
+		if state != want {
+			t.Errorf("CheckTable() = %v, want %v", state, want)
+		}
+	}
+
+	checkState(database.TableMissing)
+
+	// The table as created by versions without history columns.
+	if err := driver.Run(ctx, `CREATE TABLE history (name VARCHAR(255) NOT NULL PRIMARY KEY, checksum VARCHAR(64) NULL);`); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.Run(ctx, `INSERT INTO history (name) VALUES ('001-a.sql');`); err != nil {
+		t.Fatal(err)
+	}
+	checkState(database.TableOutdated)
+
+	script, err := driver.(database.TableUpgradeScripter).UpgradeTableScript(ctx)
+	if err != nil {
+		t.Fatalf("UpgradeTableScript() error = %v", err)
+	}
+	if strings.Contains(script, "checksum") || !strings.Contains(script, `ALTER TABLE "history" ADD COLUMN applied_at DATETIME NULL;`) {
+		t.Errorf("UpgradeTableScript() = %q, want only the missing columns added", script)
+	}
+
+	names, err := driver.AppliedNames(ctx)
+	if err != nil {
+		t.Fatalf("AppliedNames() error = %v", err)
+	}
+	if len(names) != 1 || names[0] != "001-a.sql" {
+		t.Errorf("AppliedNames() = %v, want [001-a.sql]", names)
+	}
+
+	if err := driver.EnsureTable(ctx); err != nil {
+		t.Fatalf("EnsureTable() error = %v", err)
+	}
+	checkState(database.TableCurrent)
+
+	script, err = driver.(database.TableUpgradeScripter).UpgradeTableScript(ctx)
+	if err != nil || script != "" {
+		t.Errorf("UpgradeTableScript() = %q, %v, want nothing for a current table", script, err)
+	}
+
+	applied, err := driver.AppliedMigrations(ctx)
+	if err != nil {
+		t.Fatalf("AppliedMigrations() error = %v", err)
+	}
+	if len(applied) != 1 || applied[0].Name != "001-a.sql" {
+		t.Errorf("AppliedMigrations() = %+v, want the row kept", applied)
+	}
+
+	// Ensuring a current table changes nothing.
+	if err := driver.EnsureTable(ctx); err != nil {
+		t.Fatalf("EnsureTable() again error = %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package sqlserver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// separatorPattern matches a "GO" batch separator line, with an optional
+// repeat count and trailing comment, the way sqlcmd and SSMS accept it.
+var separatorPattern = regexp.MustCompile(`(?i)^\s*GO(?:\s+(\d+))?\s*(?:--.*)?$`)
+
+// batch is a part of a migration body delimited by "GO" lines.
+type batch struct {
+	Query string
+
+	// Line is the line of the migration body the batch starts on.
+	Line uint
+
+	// Count is how many times the batch runs, set by "GO n".
+	Count int
+}
+
+// splitBatches breaks a migration body on "GO" separator lines. Batches with
+// nothing but whitespace are dropped.
+func splitBatches(body string) []batch {
+	batches := []batch{}
+	lines := strings.Split(body, "\n")
+
+	start := 0
+	for i := 0; i <= len(lines); i++ {
+		count := 1
+		if i < len(lines) {
+			match := separatorPattern.FindStringSubmatch(strings.TrimRight(lines[i], "\r"))
+			if match == nil {
+				continue
+			}
+			if match[1] != "" {
+				count, _ = strconv.Atoi(match[1])
+			}
+		}
+
+		query := strings.Join(lines[start:i], "\n")
+		if strings.TrimSpace(query) != "" && count > 0 {
+			batches = append(batches, batch{Query: query, Line: uint(start) + 1, Count: count})
+		}
+		start = i + 1
+	}
+
+	return batches
+}
@@ -0,0 +1,89 @@
+package sqlserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitBatches(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []batch
+	}{
+		{
+			name: "empty",
+			body: "\n  \n",
+			want: []batch{},
+		},
+		{
+			name: "no separator",
+			body: "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want: []batch{
+				{Query: "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n", Line: 1, Count: 1},
+			},
+		},
+		{
+			name: "separated batches",
+			body: "CREATE TABLE a (id int);\nGO\nCREATE VIEW v AS SELECT id FROM a;\nGO\n",
+			want: []batch{
+				{Query: "CREATE TABLE a (id int);", Line: 1, Count: 1},
+				{Query: "CREATE VIEW v AS SELECT id FROM a;", Line: 3, Count: 1},
+			},
+		},
+		{
+			name: "case, spacing and comments",
+			body: "SELECT 1;\n  go  \nSELECT 2;\nGo -- done\nSELECT 3;",
+			want: []batch{
+				{Query: "SELECT 1;", Line: 1, Count: 1},
+				{Query: "SELECT 2;", Line: 3, Count: 1},
+				{Query: "SELECT 3;", Line: 5, Count: 1},
+			},
+		},
+		{
+			name: "repeat count",
+			body: "INSERT INTO a DEFAULT VALUES;\nGO 3\n",
+			want: []batch{
+				{Query: "INSERT INTO a DEFAULT VALUES;", Line: 1, Count: 3},
+			},
+		},
+		{
+			name: "zero repeat count",
+			body: "SELECT 1;\nGO 0\nSELECT 2;",
+			want: []batch{
+				{Query: "SELECT 2;", Line: 3, Count: 1},
+			},
+		},
+		{
+			name: "empty batches",
+			body: "GO\n\nGO\nSELECT 1;\nGO\nGO",
+			want: []batch{
+				{Query: "SELECT 1;", Line: 4, Count: 1},
+			},
+		},
+		{
+			name: "carriage returns",
+			body: "SELECT 1;\r\nGO\r\nSELECT 2;\r\n",
+			want: []batch{
+				{Query: "SELECT 1;\r", Line: 1, Count: 1},
+				{Query: "SELECT 2;\r\n", Line: 3, Count: 1},
+			},
+		},
+		{
+			name: "GO inside a line is not a separator",
+			body: "SELECT 'GO';\nGOTO done;\nGO\n",
+			want: []batch{
+				{Query: "SELECT 'GO';\nGOTO done;", Line: 1, Count: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitBatches(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitBatches(%q)\n got %#v\nwant %#v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package sqlserver
+
+import (
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+func (ss *SQLServer) NewScripter(url string) (database.Scripter, error) {
+	table, nameColumn, err := database.ScriptTable(url, DefaultMigrationsTable, DefaultMigrationsNameColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLServer{config: &Config{MigrationsTable: table, MigrationsNameColumn: nameColumn}}, nil
+}
+
+func (ss *SQLServer) EnsureTableScript() string {
+	var script strings.Builder
+	script.WriteString(ss.createTableQuery())
+	script.WriteString("\nGO\n")
+
+	for _, column := range historyColumns {
+		script.WriteString(`IF COL_LENGTH(` + database.QuoteLiteral(ss.config.MigrationsTable) + `, ` + database.QuoteLiteral(column.Name) + `) IS NULL
+	ALTER TABLE "` + ss.config.MigrationsTable + `" ADD ` + column.Name + ` ` + column.Type + `;
+GO
+`)
+	}
+
+	return script.String()
+}
+
+// MarkAsAppliedScript starts a new batch, so the insert cannot end up in the
+// body of a procedure or view created by the last batch of the migration.
+func (ss *SQLServer) MarkAsAppliedScript(migration database.AppliedMigration) string {
+	return "GO\n" + ss.markAsAppliedStatement(migration) + "\nGO\n"
+}
+
+func (ss *SQLServer) RemoveAppliedScript(migration string) string {
+	return "GO\n" +
+		`DELETE FROM "` + ss.config.MigrationsTable + `" WHERE ` + ss.config.MigrationsNameColumn + ` = ` + database.QuoteLiteral(migration) + `;` +
+		"\nGO\n"
+}
+
+// GuardedScript runs every batch of the migration through EXEC behind an
+// existence check, as an IF cannot span batches. Inside EXEC each batch is
+// still the first statement of its own batch, which CREATE PROCEDURE and
+// CREATE VIEW require. The batches and the insert share one transaction
+// opened with XACT_ABORT, so a failing batch rolls back the earlier ones and
+// the later ones skip themselves on XACT_STATE, leaving nothing to re-execute
+// when the script is run again. Errors raised with RAISERROR do not abort the
+// transaction, so a migration should use THROW to fail.
+func (ss *SQLServer) GuardedScript(migration database.AppliedMigration, body string) (string, error) {
+	guard := `IF XACT_STATE() = 1 AND NOT EXISTS (SELECT 1 FROM "` + ss.config.MigrationsTable + `" WHERE ` + ss.config.MigrationsNameColumn + ` = ` + database.QuoteLiteral(migration.Name) + `)`
+
+	var script strings.Builder
+	script.WriteString("SET XACT_ABORT ON;\nBEGIN TRANSACTION;\nGO\n")
+
+	for _, b := range splitBatches(body) {
+		for n := 0; n < b.Count; n++ {
+			script.WriteString(guard + "\nBEGIN\n\tEXEC(N" + database.QuoteLiteral(b.Query) + ");\nEND;\nGO\n")
+		}
+	}
+
+	script.WriteString(guard + "\nBEGIN\n\t" + ss.markAsAppliedStatement(migration) + "\nEND;\n")
+	script.WriteString("IF XACT_STATE() = 1\n\tCOMMIT TRANSACTION;\n")
+	script.WriteString("ELSE\nBEGIN\n\tIF XACT_STATE() = -1\n\t\tROLLBACK TRANSACTION;\n")
+	script.WriteString("\tRAISERROR(N'%s failed and was rolled back.', 16, 1, " + "N" + database.QuoteLiteral(migration.Name) + ");\nEND;\nGO\n")
+
+	return script.String(), nil
+}
+
+func (ss *SQLServer) markAsAppliedStatement(migration database.AppliedMigration) string {
+	return `INSERT INTO "` + ss.config.MigrationsTable + `" (` + ss.config.MigrationsNameColumn + `, ` + database.HistoryColumns + `) VALUES (` +
+		database.QuoteLiteral(migration.Name) + `, ` + migration.HistoryLiterals("SYSDATETIMEOFFSET()", database.QuoteLiteral) + `);`
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	nurl "net/url"
+	"strconv"
+	"time"
 
 	"github.com/allanmaral/gomigrate/internal/database"
 	mssql "github.com/microsoft/go-mssqldb"
@@ -24,6 +26,9 @@ var (
 	ErrCreateMigrationTable = fmt.Errorf("failed to create migration table")
 )
 
+// historyColumns declares the history columns with SQL Server types.
+var historyColumns = database.HistoryColumnTypes("DATETIMEOFFSET", "BIT")
+
 type Config struct {
 	MigrationsTable      string
 	MigrationsNameColumn string
@@ -38,19 +43,19 @@ type SQLServer struct {
 	config *Config
 }
 
-func WithInstance(instance *sql.DB, config *Config) (database.Driver, error) {
+func WithInstance(ctx context.Context, instance *sql.DB, config *Config) (database.Driver, error) {
 	if config == nil {
 		return nil, ErrNilConfig
 	}
 
-	if err := instance.Ping(); err != nil {
+	if err := instance.PingContext(ctx); err != nil {
 		return nil, err
 	}
 
 	if config.DatabaseName == "" {
 		query := `SELECT DB_NAME()`
 		var databaseName string
-		if err := instance.QueryRow(query).Scan(&databaseName); err != nil {
+		if err := instance.QueryRowContext(ctx, query).Scan(&databaseName); err != nil {
 			return nil, &database.Error{OrigErr: err, Query: []byte(query)}
 		}
 
@@ -64,7 +69,7 @@ func WithInstance(instance *sql.DB, config *Config) (database.Driver, error) {
 	if config.SchemaName == "" {
 		query := `SELECT SCHEMA_NAME()`
 		var schemaName string
-		if err := instance.QueryRow(query).Scan(&schemaName); err != nil {
+		if err := instance.QueryRowContext(ctx, query).Scan(&schemaName); err != nil {
 			return nil, &database.Error{OrigErr: err, Query: []byte(query)}
 		}
 
@@ -83,7 +88,7 @@ func WithInstance(instance *sql.DB, config *Config) (database.Driver, error) {
 		config.MigrationsNameColumn = DefaultMigrationsNameColumn
 	}
 
-	conn, err := instance.Conn(context.Background())
+	conn, err := instance.Conn(ctx)
 
 	if err != nil {
 		return nil, err
@@ -95,10 +100,6 @@ func WithInstance(instance *sql.DB, config *Config) (database.Driver, error) {
 		config: config,
 	}
 
-	if err := ss.ensureMigrationsTable(); err != nil {
-		return nil, err
-	}
-
 	return ss, nil
 }
 
@@ -134,7 +135,7 @@ func (ss *SQLServer) Url(conf *database.ConnectionParams) *nurl.URL {
 	}
 }
 
-func (ss *SQLServer) Open(url string) (database.Driver, error) {
+func (ss *SQLServer) Open(ctx context.Context, url string) (database.Driver, error) {
 	purl, err := nurl.Parse(url)
 	if err != nil {
 		return nil, err
@@ -150,7 +151,7 @@ func (ss *SQLServer) Open(url string) (database.Driver, error) {
 		return nil, err
 	}
 
-	driver, err := WithInstance(db, &Config{
+	driver, err := WithInstance(ctx, db, &Config{
 		DatabaseName:         purl.Path,
 		MigrationsTable:      migrationsTable,
 		MigrationsNameColumn: nameColumn,
@@ -172,44 +173,72 @@ func (ss *SQLServer) Close() error {
 	return nil
 }
 
-func (ss *SQLServer) Run(migration string) error {
-	if _, err := ss.conn.ExecContext(context.Background(), migration); err != nil {
-		if msErr, ok := err.(mssql.Error); ok {
-			message := fmt.Sprintf("migration failed: %s", msErr.Message)
-			if msErr.ProcName != "" {
-				message = fmt.Sprintf("%s (proc name %s)", msErr.Message, msErr.ProcName)
+func (ss *SQLServer) Run(ctx context.Context, migration string) error {
+	return ss.run(ctx, ss.conn, migration)
+}
+
+// run executes the migration one batch at a time, splitting it on "GO" lines.
+// Errors report the batch that failed and the line of the migration body, as
+// the server only knows the line within the batch.
+func (ss *SQLServer) run(ctx context.Context, ex database.Execer, migration string) error {
+	batches := splitBatches(migration)
+
+	for i, b := range batches {
+		for n := 0; n < b.Count; n++ {
+			if _, err := ex.ExecContext(ctx, b.Query); err != nil {
+				batchInfo := ""
+				if len(batches) > 1 {
+					batchInfo = fmt.Sprintf(" in batch %d of %d", i+1, len(batches))
+				}
+
+				if msErr, ok := err.(mssql.Error); ok {
+					message := fmt.Sprintf("migration failed%s: %s", batchInfo, msErr.Message)
+					if msErr.ProcName != "" {
+						message = fmt.Sprintf("%s (proc name %s)", message, msErr.ProcName)
+					}
+					line := uint(0)
+					if msErr.LineNo > 0 {
+						line = b.Line + uint(msErr.LineNo) - 1
+					}
+					return database.Error{OrigErr: err, Err: message, Query: []byte(b.Query), Line: line}
+				}
+				return database.Error{OrigErr: err, Err: "migration failed" + batchInfo, Query: []byte(b.Query)}
 			}
-			return database.Error{OrigErr: err, Err: message, Query: []byte(migration), Line: uint(msErr.LineNo)}
 		}
-		return database.Error{OrigErr: err, Err: "migration failed", Query: []byte(migration)}
 	}
 
 	return nil
 }
 
-func (ss *SQLServer) AppliedMigrations() ([]string, error) {
+func (ss *SQLServer) AppliedMigrations(ctx context.Context) ([]database.AppliedMigration, error) {
 	rows, err := ss.conn.QueryContext(
-		context.Background(),
-		`SELECT `+ss.config.MigrationsNameColumn+` FROM "`+ss.config.MigrationsTable+`" ORDER BY "`+ss.config.MigrationsNameColumn+`";`)
+		ctx,
+		`SELECT `+ss.config.MigrationsNameColumn+`, `+database.HistoryColumns+` FROM "`+ss.config.MigrationsTable+`" ORDER BY `+ss.config.MigrationsNameColumn+`;`)
 	if err != nil {
 		return nil, err
 	}
 
-	migrations := []string{}
-	for rows.Next() {
-		var migrationName string
-		rows.Scan(&migrationName)
-		migrations = append(migrations, migrationName)
+	return database.ScanAppliedMigrations(rows)
+}
+
+func (ss *SQLServer) AppliedNames(ctx context.Context) ([]string, error) {
+	rows, err := ss.conn.QueryContext(ctx, `SELECT `+ss.config.MigrationsNameColumn+` FROM "`+ss.config.MigrationsTable+`" ORDER BY `+ss.config.MigrationsNameColumn+`;`)
+	if err != nil {
+		return nil, err
 	}
 
-	return migrations, nil
+	return database.ScanAppliedNames(rows)
 }
 
-func (ss *SQLServer) MarkAsApplied(migration string) error {
-	_, err := ss.conn.ExecContext(
-		context.Background(),
-		`INSERT INTO "`+ss.config.MigrationsTable+`" (`+ss.config.MigrationsNameColumn+`) VALUES (@p1);`,
-		migration)
+func (ss *SQLServer) MarkAsApplied(ctx context.Context, migration database.AppliedMigration) error {
+	return ss.markAsApplied(ctx, ss.conn, migration)
+}
+
+func (ss *SQLServer) markAsApplied(ctx context.Context, ex database.Execer, migration database.AppliedMigration) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`INSERT INTO "`+ss.config.MigrationsTable+`" (`+ss.config.MigrationsNameColumn+`, `+database.HistoryColumns+`) VALUES (@p1, @p2, @p3, @p4, @p5, @p6, @p7, @p8, @p9);`,
+		append([]any{migration.Name}, migration.HistoryValues()...)...)
 	if err != nil {
 		return fmt.Errorf("failed to mark migration as applied")
 	}
@@ -217,20 +246,174 @@ func (ss *SQLServer) MarkAsApplied(migration string) error {
 	return nil
 }
 
-func (ss *SQLServer) RemoveApplied(migration string) error {
-	_, err := ss.conn.ExecContext(
-		context.Background(),
+func (ss *SQLServer) RemoveApplied(ctx context.Context, migration string) error {
+	return ss.removeApplied(ctx, ss.conn, migration)
+}
+
+func (ss *SQLServer) removeApplied(ctx context.Context, ex database.Execer, migration string) error {
+	_, err := ex.ExecContext(
+		ctx,
 		`DELETE FROM "`+ss.config.MigrationsTable+`" WHERE `+ss.config.MigrationsNameColumn+` = @p1;`,
 		migration)
 	if err != nil {
-		return fmt.Errorf("failed to mark migration as applied")
+		return fmt.Errorf("failed to remove applied migration")
+	}
+
+	return nil
+}
+
+func (ss *SQLServer) EnsureUnmarkedTable(ctx context.Context) error {
+	query := `IF OBJECT_ID(N` + database.QuoteLiteral(ss.unmarkedTable()) + `, N'U') IS NULL
+		CREATE TABLE "` + ss.unmarkedTable() + `"
+		(
+				` + ss.config.MigrationsNameColumn + ` VARCHAR(255) NOT NULL,
+				unmarked_at DATETIMEOFFSET NOT NULL,
+				tool_version VARCHAR(64) NULL,
+				unmarked_by VARCHAR(255) NULL,
+				hostname VARCHAR(255) NULL,
+				reason VARCHAR(1000) NULL
+		);`
+
+	if _, err := ss.conn.ExecContext(ctx, query); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return nil
+}
+
+func (ss *SQLServer) recordUnmarked(ctx context.Context, ex database.Execer, unmarked database.Unmarked) error {
+	_, err := ex.ExecContext(
+		ctx,
+		`INSERT INTO "`+ss.unmarkedTable()+`" (`+ss.config.MigrationsNameColumn+`, `+database.UnmarkedColumns+`) VALUES (@p1, @p2, @p3, @p4, @p5, @p6);`,
+		append([]any{unmarked.Name}, unmarked.UnmarkedValues()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to record unmarked migration")
 	}
 
 	return nil
 }
 
-func (ss *SQLServer) ensureMigrationsTable() error {
-	query := `IF NOT EXISTS
+func (ss *SQLServer) UpdateChecksum(ctx context.Context, migration string, checksum string) error {
+	_, err := ss.conn.ExecContext(
+		ctx,
+		`UPDATE "`+ss.config.MigrationsTable+`" SET checksum = @p1 WHERE `+ss.config.MigrationsNameColumn+` = @p2;`,
+		checksum, migration)
+	if err != nil {
+		return fmt.Errorf("failed to update migration checksum")
+	}
+
+	return nil
+}
+
+func (ss *SQLServer) SetDirty(ctx context.Context, migration string, dirty bool) error {
+	_, err := ss.conn.ExecContext(
+		ctx,
+		`UPDATE "`+ss.config.MigrationsTable+`" SET dirty = @p1 WHERE `+ss.config.MigrationsNameColumn+` = @p2;`,
+		dirty, migration)
+	if err != nil {
+		return fmt.Errorf("failed to update migration dirty mark")
+	}
+
+	return nil
+}
+
+func (ss *SQLServer) UpdateApplied(ctx context.Context, migration database.AppliedMigration) error {
+	_, err := ss.conn.ExecContext(
+		ctx,
+		`UPDATE "`+ss.config.MigrationsTable+`" SET `+database.HistoryAssignments(func(i int) string { return "@p" + strconv.Itoa(i) })+` WHERE `+ss.config.MigrationsNameColumn+` = @p9;`,
+		append(migration.HistoryValues(), migration.Name)...)
+	if err != nil {
+		return fmt.Errorf("failed to update applied migration")
+	}
+
+	return nil
+}
+
+func (ss *SQLServer) Query(ctx context.Context, query string) (*sql.Rows, error) {
+	return ss.conn.QueryContext(ctx, query)
+}
+
+func (ss *SQLServer) Begin(ctx context.Context) (database.Tx, error) {
+	tx, err := ss.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlServerTx{tx: tx, ss: ss}, nil
+}
+
+func (ss *SQLServer) Transactional() bool {
+	return true
+}
+
+// Lock takes a session owned application lock through sp_getapplock, which is
+// released by Unlock or when the connection closes.
+func (ss *SQLServer) Lock(ctx context.Context, timeout time.Duration) error {
+	query := `DECLARE @result INT;
+		EXEC @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = @p2;
+		SELECT @result;`
+
+	var result int
+	if err := ss.conn.QueryRowContext(ctx, query, ss.lockResource(), timeout.Milliseconds()).Scan(&result); err != nil {
+		return &database.Error{OrigErr: err, Err: "failed to take migrations lock", Query: []byte(query)}
+	}
+
+	// sp_getapplock returns 0 or 1 when the lock is granted, -1 on timeout and
+	// other negative values when the request failed.
+	switch {
+	case result >= 0:
+		return nil
+	case result == -1:
+		return fmt.Errorf("%w (waited %s for lock %s)", database.ErrLocked, timeout, ss.lockResource())
+	default:
+		return fmt.Errorf("failed to take migrations lock %s: sp_getapplock returned %d", ss.lockResource(), result)
+	}
+}
+
+func (ss *SQLServer) Unlock(ctx context.Context) error {
+	query := `EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session';`
+	if _, err := ss.conn.ExecContext(ctx, query, ss.lockResource()); err != nil {
+		return &database.Error{OrigErr: err, Err: "failed to release migrations lock", Query: []byte(query)}
+	}
+
+	return nil
+}
+
+func (ss *SQLServer) lockResource() string {
+	return "gomigrate:" + ss.config.DatabaseName + "." + ss.config.SchemaName + "." + ss.config.MigrationsTable
+}
+
+func (ss *SQLServer) EnsureTable(ctx context.Context) error {
+	return ss.ensureMigrationsTable(ctx)
+}
+
+func (ss *SQLServer) CheckTable(ctx context.Context) (database.TableState, error) {
+	query := `SELECT c.name FROM sys.columns c
+		JOIN sys.tables t ON (c.object_id = t.object_id)
+		JOIN sys.schemas s ON (t.schema_id = s.schema_id)
+		WHERE s.name = 'dbo' AND t.name = @p1;`
+
+	rows, err := ss.conn.QueryContext(ctx, query, ss.config.MigrationsTable)
+	if err != nil {
+		return database.TableMissing, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return database.ScanTableState(rows)
+}
+
+func (ss *SQLServer) ensureMigrationsTable(ctx context.Context) error {
+	query := ss.createTableQuery()
+
+	if _, err := ss.conn.ExecContext(ctx, query); err != nil {
+		// return ErrCreateMigrationTable
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	return ss.ensureHistoryColumns(ctx)
+}
+
+func (ss *SQLServer) createTableQuery() string {
+	return `IF NOT EXISTS
 		 (SELECT *
 			FROM sys.tables t
 								JOIN sys.schemas s ON (t.schema_id = s.schema_id)
@@ -241,11 +424,54 @@ func (ss *SQLServer) ensureMigrationsTable() error {
 				` + ss.config.MigrationsNameColumn + ` VARCHAR(255) NOT NULL PRIMARY KEY,
 				CONSTRAINT UN__` + ss.config.MigrationsTable + `__` + ss.config.MigrationsNameColumn + ` UNIQUE (` + ss.config.MigrationsNameColumn + `)
 		);`
+}
 
-	if _, err := ss.conn.ExecContext(context.Background(), query); err != nil {
-		// return ErrCreateMigrationTable
-		return &database.Error{OrigErr: err, Query: []byte(query)}
+func (ss *SQLServer) ensureHistoryColumns(ctx context.Context) error {
+	for _, column := range historyColumns {
+		query := `IF COL_LENGTH(@p1, @p2) IS NULL
+		ALTER TABLE "` + ss.config.MigrationsTable + `" ADD ` + column.Name + ` ` + column.Type + `;`
+
+		if _, err := ss.conn.ExecContext(ctx, query, ss.config.MigrationsTable, column.Name); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
 	}
 
 	return nil
 }
+
+func (ss *SQLServer) unmarkedTable() string {
+	return ss.config.MigrationsTable + "_unmarked"
+}
+
+type sqlServerTx struct {
+	tx *sql.Tx
+	ss *SQLServer
+}
+
+func (t *sqlServerTx) Run(ctx context.Context, migration string) error {
+	return t.ss.run(ctx, t.tx, migration)
+}
+
+func (t *sqlServerTx) RunFunc(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return fn(ctx, t.tx)
+}
+
+func (t *sqlServerTx) MarkAsApplied(ctx context.Context, migration database.AppliedMigration) error {
+	return t.ss.markAsApplied(ctx, t.tx, migration)
+}
+
+func (t *sqlServerTx) RemoveApplied(ctx context.Context, migration string) error {
+	return t.ss.removeApplied(ctx, t.tx, migration)
+}
+
+func (t *sqlServerTx) RecordUnmarked(ctx context.Context, unmarked database.Unmarked) error {
+	return t.ss.recordUnmarked(ctx, t.tx, unmarked)
+}
+
+func (t *sqlServerTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqlServerTx) Rollback() error {
+	return t.tx.Rollback()
+}
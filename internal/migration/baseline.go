@@ -0,0 +1,82 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+)
+
+// Baseline records every local migration up to and including version as
+// applied without running it, for databases whose schema predates gomigrate.
+// Opening the database creates the migrations table. A database with history
+// is refused unless force is set, in which case only the migrations not
+// recorded yet are added. It returns the migrations it recorded.
+func Baseline(ctx context.Context, version string, force bool, conf *config.Config) ([]string, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, release, err := openLocked(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	appliedMigrations, err := driver.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(appliedMigrations) > 0 && !force {
+		return nil, fmt.Errorf("the migrations table already has %d migrations, use --force to baseline it anyway", len(appliedMigrations))
+	}
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := resolveTarget(version, localMigrations)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := make(map[string]bool, len(appliedMigrations))
+	for _, migration := range appliedMigrations {
+		recorded[migration.Name] = true
+	}
+
+	baselined := []string{}
+	for _, migration := range localMigrations {
+		if compareMigrations(migration, target) > 0 {
+			continue
+		}
+
+		if recorded[migration] {
+			logf(conf, "== %s: already recorded, skipped\n", migration)
+			continue
+		}
+
+		mig, err := readMigrationFile(migration, conf)
+		if err != nil {
+			return baselined, err
+		}
+
+		record := appliedRecord(migration, mig, time.Now(), conf)
+		if record.Reason == "" {
+			record.Reason = "baseline"
+		}
+
+		if err := driver.MarkAsApplied(ctx, record); err != nil {
+			return baselined, err
+		}
+
+		logf(conf, "== %s: baselined\n", migration)
+		baselined = append(baselined, migration)
+	}
+
+	logf(conf, "Baselined %d migrations up to %s.\n", len(baselined), target)
+
+	return baselined, nil
+}
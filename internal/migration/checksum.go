@@ -0,0 +1,214 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+// VerifyMigrations compares the applied migrations with their local files and
+// fails when any of them was modified after being applied.
+func VerifyMigrations(ctx context.Context, conf *config.Config) error {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, appliedMigrations, err := openReadOnly(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return err
+	}
+
+	modified, unrecorded, err := compareChecksums(appliedMigrations, localMigrations, conf)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range unrecorded {
+		logf(conf, "Warning: no checksum recorded for %s, run \"gomigrate repair\" to record it.\n", migration)
+	}
+
+	if len(modified) > 0 {
+		return modifiedMigrationsError(modified)
+	}
+
+	logf(conf, "All applied migrations match their files.\n")
+
+	return nil
+}
+
+// RepairChecksums records the current checksum of applied migrations, for
+// when a migration was changed on purpose. With no names, every applied
+// migration with a local file is repaired. It returns the migrations whose
+// checksum changed.
+func RepairChecksums(ctx context.Context, names []string, conf *config.Config) ([]string, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, release, err := openLocked(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	appliedMigrations, err := driver.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]bool, len(localMigrations))
+	for _, migration := range localMigrations {
+		local[migration] = true
+	}
+
+	applied := make(map[string]database.AppliedMigration, len(appliedMigrations))
+	for _, migration := range appliedMigrations {
+		applied[migration.Name] = migration
+	}
+
+	if len(names) == 0 {
+		for _, migration := range appliedMigrations {
+			if local[migration.Name] {
+				names = append(names, migration.Name)
+			}
+		}
+	}
+
+	repaired := []string{}
+	for _, name := range names {
+		recorded, ok := applied[name]
+		if !ok {
+			return nil, fmt.Errorf("migration %s has not been applied", name)
+		}
+
+		if !local[name] {
+			return nil, fmt.Errorf("migration %s was not found in %s", name, migrationSource(conf))
+		}
+
+		mig, err := readMigrationFile(name, conf)
+		if err != nil {
+			return nil, err
+		}
+
+		checksum := mig.Checksum()
+		if checksum == recorded.Checksum {
+			continue
+		}
+
+		if err := driver.UpdateChecksum(ctx, name, checksum); err != nil {
+			return nil, err
+		}
+
+		logf(conf, "== %s: checksum recorded\n", name)
+		repaired = append(repaired, name)
+	}
+
+	if len(repaired) == 0 {
+		logf(conf, "No checksums were changed.\n")
+	}
+
+	return repaired, nil
+}
+
+// compareChecksums returns the applied migrations whose local file no longer
+// matches the recorded checksum, and those applied before checksums were
+// recorded. Migrations without a local file are skipped, and so are
+// repeatable migrations, which are meant to change, and Go migrations, which
+// have no checksum.
+func compareChecksums(applied []database.AppliedMigration, localMigrations []string, conf *config.Config) ([]string, []string, error) {
+	local := make(map[string]bool, len(localMigrations))
+	for _, migration := range localMigrations {
+		local[migration] = true
+	}
+
+	modified := []string{}
+	unrecorded := []string{}
+	for _, migration := range applied {
+		if _, ok := goMigrations()[migration.Name]; ok || !local[migration.Name] || isRepeatable(migration.Name) {
+			continue
+		}
+
+		if migration.Checksum == "" {
+			unrecorded = append(unrecorded, migration.Name)
+			continue
+		}
+
+		mig, err := readMigrationFile(migration.Name, conf)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if mig.Checksum() != migration.Checksum {
+			modified = append(modified, migration.Name)
+		}
+	}
+
+	return modified, unrecorded, nil
+}
+
+// changedRepeatables returns the applied repeatable migrations whose local
+// file changed since they last ran, so they have to run again.
+func changedRepeatables(applied []database.AppliedMigration, localMigrations []string, conf *config.Config) ([]string, error) {
+	local := make(map[string]bool, len(localMigrations))
+	for _, migration := range localMigrations {
+		local[migration] = true
+	}
+
+	changed := []string{}
+	for _, migration := range applied {
+		if !local[migration.Name] || !isRepeatable(migration.Name) {
+			continue
+		}
+
+		mig, err := readMigrationFile(migration.Name, conf)
+		if err != nil {
+			return nil, err
+		}
+
+		if mig.Checksum() != migration.Checksum {
+			changed = append(changed, migration.Name)
+		}
+	}
+
+	return changed, nil
+}
+
+func modifiedMigrationsError(modified []string) error {
+	return fmt.Errorf("applied migrations were modified: %s; restore them, or run \"gomigrate repair\" if the change was intended",
+		strings.Join(modified, ", "))
+}
+
+// appliedNames returns the names of the applied migrations in the order they
+// are applied.
+func appliedNames(applied []database.AppliedMigration) []string {
+	names := make([]string, len(applied))
+	for i, migration := range applied {
+		names[i] = migration.Name
+	}
+	sortMigrations(names)
+	return names
+}
+
+// revertibleNames leaves the repeatable migrations out of names, as they are
+// never reverted.
+func revertibleNames(names []string) []string {
+	revertible := []string{}
+	for _, name := range names {
+		if !isRepeatable(name) {
+			revertible = append(revertible, name)
+		}
+	}
+	return revertible
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -12,44 +11,23 @@ import (
 	"github.com/gosimple/slug"
 )
 
-var migrationTemplate = `
-BEGIN -- UP
-
---  Add altering commands here.
--- 
---  Example:
---  CREATE TABLE users (
---    user_id INT,
---    last_name VARCHAR(255),
---    first_name VARCHAR(255),
---    created_at TIMESTAMPTZ 
---  );
-
-END -- UP
-
-
-BEGIN -- DOWN
-
--- Add reverting commands here.
---
--- Example:
--- DROP TABLE users;
-
-END -- DOWN
-`
-
 func NewMigration(name string, c *config.Config) error {
 	now := formatDate(time.Now())
 	name = slug.Make(name)
 
-	filename := fmt.Sprintf("%s-%s.sql", now, name)
-	path := filepath.Join(c.MigrationsPath, filename)
+	format, err := migrationFormat(c)
+	if err != nil {
+		return err
+	}
 
-	if err := createFile(path, []byte(migrationTemplate)); err != nil {
+	paths, err := format.Create(c.MigrationsPath, now, name)
+	if err != nil {
 		return err
 	}
 
-	fmt.Printf("New migration was created at \"%s\".\n", path)
+	for _, path := range paths {
+		fmt.Printf("New migration was created at \"%s\".\n", path)
+	}
 
 	return nil
 }
@@ -0,0 +1,105 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+// markDirty records a migration as applied but dirty, before it runs. A
+// repeatable migration that ran before has its row updated instead.
+func markDirty(ctx context.Context, driver database.Driver, record database.AppliedMigration) error {
+	record.Dirty = true
+
+	if isRepeatable(record.Name) {
+		appliedMigrations, err := driver.AppliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range appliedMigrations {
+			if migration.Name == record.Name {
+				return driver.UpdateApplied(ctx, record)
+			}
+		}
+	}
+
+	return driver.MarkAsApplied(ctx, record)
+}
+
+// checkDirty fails when a migration was left dirty, as running more
+// migrations on a partially migrated database could make things worse.
+func checkDirty(appliedMigrations []database.AppliedMigration) error {
+	for _, migration := range appliedMigrations {
+		if migration.Dirty {
+			return fmt.Errorf("migration %s failed partway through and the database may be partially migrated, fix it by hand and then run \"gomigrate force <version>\" with the last migration the database is fully migrated to", migration.Name)
+		}
+	}
+
+	return nil
+}
+
+// ForceVersion clears the dirty marks after an operator fixed the database by
+// hand. Dirty migrations up to and including version are kept as applied, the
+// ones after it are recorded as not applied. It returns the migrations whose
+// mark was cleared.
+func ForceVersion(ctx context.Context, version string, conf *config.Config) ([]string, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, release, err := openLocked(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	appliedMigrations, err := driver.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The version may name a migration whose file was removed while fixing
+	// things, so the applied migrations are searched as well.
+	known := findMissingMigrations(localMigrations, appliedNames(appliedMigrations))
+	known = append(known, localMigrations...)
+	sortMigrations(known)
+
+	target, err := resolveTarget(version, known)
+	if err != nil {
+		return nil, err
+	}
+
+	cleared := []string{}
+	for _, migration := range appliedMigrations {
+		if !migration.Dirty {
+			continue
+		}
+
+		if compareMigrations(migration.Name, target) <= 0 {
+			if err := driver.SetDirty(ctx, migration.Name, false); err != nil {
+				return cleared, err
+			}
+			logf(conf, "== %s: recorded as applied\n", migration.Name)
+		} else {
+			if err := driver.RemoveApplied(ctx, migration.Name); err != nil {
+				return cleared, err
+			}
+			logf(conf, "== %s: recorded as not applied\n", migration.Name)
+		}
+
+		cleared = append(cleared, migration.Name)
+	}
+
+	if len(cleared) == 0 {
+		logf(conf, "No dirty migrations found.\n")
+	}
+
+	return cleared, nil
+}
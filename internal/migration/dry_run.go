@@ -0,0 +1,118 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+// openRunConnection opens the database for a run or an undo, takes the lock
+// and reads the applied migrations. A dry run only reads from the database, so
+// it neither takes the lock nor creates or upgrades the migrations table,
+// which is written to the script instead. The returned function releases the
+// database.
+func openRunConnection(ctx context.Context, conf *config.Config) (database.Driver, []database.AppliedMigration, func(), error) {
+	if conf.DryRun == nil {
+		driver, release, err := openLocked(ctx, conf)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		appliedMigrations, err := driver.AppliedMigrations(ctx)
+		if err != nil {
+			release()
+			return nil, nil, nil, err
+		}
+
+		return driver, appliedMigrations, release, nil
+	}
+
+	driver, appliedMigrations, err := openReadOnly(ctx, conf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	release := func() {
+		driver.Close()
+	}
+
+	return driver, appliedMigrations, release, nil
+}
+
+// writeScript writes the SQL that reverting down and then applying up would
+// execute, bookkeeping statements included, to conf.DryRun without running
+// any of it. The script starts by creating or upgrading the migrations table,
+// which a run does when it connects.
+func writeScript(ctx context.Context, driver database.Driver, down []string, up []string, conf *config.Config) (*Result, error) {
+	out := conf.DryRun
+	result := &Result{DryRun: true}
+
+	fmt.Fprintln(out, driver.EnsureTableScript())
+	if upgrader, ok := driver.(database.TableUpgradeScripter); ok {
+		script, err := upgrader.UpgradeTableScript(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if script != "" {
+			fmt.Fprintln(out, script)
+		}
+	}
+
+	write := func(migration string, direction string) error {
+		mig, err := readMigrationFile(migration, conf)
+		if err != nil {
+			return err
+		}
+
+		if mig.UpFunc != nil {
+			return fmt.Errorf("%s is a Go migration and cannot be written as SQL", migration)
+		}
+
+		if direction == DirectionDown && mig.Irreversible {
+			return fmt.Errorf("%s is irreversible and cannot be reverted", migration)
+		}
+
+		if direction == DirectionUp {
+			fmt.Fprintf(out, "-- %s (UP)\n", migration)
+		} else {
+			fmt.Fprintf(out, "-- %s (DOWN)\n", migration)
+		}
+
+		if mig.NoTransaction {
+			fmt.Fprintln(out, "-- gomigrate:no-transaction")
+		}
+
+		if direction == DirectionUp {
+			fmt.Fprintln(out, strings.TrimSpace(mig.Up))
+			// A repeatable migration that ran before replaces its old record.
+			if isRepeatable(migration) {
+				fmt.Fprintln(out, driver.RemoveAppliedScript(migration))
+			}
+			fmt.Fprintln(out, driver.MarkAsAppliedScript(appliedRecord(migration, mig, time.Now(), conf)))
+		} else {
+			fmt.Fprintln(out, strings.TrimSpace(mig.Down))
+			fmt.Fprintln(out, driver.RemoveAppliedScript(migration))
+		}
+
+		result.Migrations = append(result.Migrations, MigrationResult{Name: migration, Direction: direction})
+		return nil
+	}
+
+	for _, migration := range down {
+		if err := write(migration, DirectionDown); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, migration := range up {
+		if err := write(migration, DirectionUp); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,268 @@
+package migration
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/source"
+)
+
+// Flyway migration names. Versions are dot or underscore separated numbers,
+// like "V1.2__add_users.sql" or "V1_2__add_users.sql". Undo migrations share
+// the version of the migration they revert.
+var (
+	flywayVersionedPattern  = regexp.MustCompile(`^V(\d+(?:[._]\d+)*)__(.+)\.sql$`)
+	flywayUndoPattern       = regexp.MustCompile(`^U(\d+(?:[._]\d+)*)__(.+)\.sql$`)
+	flywayRepeatablePattern = regexp.MustCompile(`^R__(.+)\.sql$`)
+)
+
+// flywayFormat reads Flyway versioned "V<version>__<description>.sql" and
+// repeatable "R__<description>.sql" migrations. The migration name is the
+// file name. A versioned migration is reverted by the "U<version>__" file
+// with its version, and is irreversible without one. Repeatable migrations
+// run again whenever their checksum changes, after the versioned ones.
+type flywayFormat struct{}
+
+func (flywayFormat) List(src source.Source) ([]string, error) {
+	files, err := src.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []string{}
+	versions := map[string]string{}
+	undos := map[string]string{}
+	for _, name := range files {
+		if !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		if version, ok := flywayVersion(name); ok {
+			if other, dup := versions[version]; dup {
+				return nil, fmt.Errorf("%s and %s have the same version %s", other, name, version)
+			}
+			versions[version] = name
+			migrations = append(migrations, name)
+			continue
+		}
+
+		if match := flywayUndoPattern.FindStringSubmatch(name); match != nil {
+			undos[normalizeVersion(match[1])] = name
+			continue
+		}
+
+		if isRepeatable(name) {
+			migrations = append(migrations, name)
+			continue
+		}
+
+		return nil, fmt.Errorf("%s is not a Flyway migration, expected a name like V1__description.sql or R__description.sql", name)
+	}
+
+	for version, undo := range undos {
+		if _, ok := versions[version]; !ok {
+			return nil, fmt.Errorf("%s has no matching versioned migration", undo)
+		}
+	}
+
+	sortMigrations(migrations)
+
+	return migrations, nil
+}
+
+func (flywayFormat) Read(src source.Source, migration string) (*Migration, error) {
+	up, err := src.ReadFile(migration)
+	if err != nil {
+		return nil, err
+	}
+
+	mig := &Migration{Up: string(up), UpLine: 1, UpFile: migration}
+	if err := parseDirectives(migration, leadingComments(mig.Up), mig); err != nil {
+		return nil, err
+	}
+
+	version, ok := flywayVersion(migration)
+	if !ok {
+		mig.Irreversible = true
+		return mig, nil
+	}
+
+	undo, err := findFlywayUndo(src, version)
+	if err != nil {
+		return nil, err
+	}
+	if undo == "" {
+		mig.Irreversible = true
+		return mig, nil
+	}
+
+	down, err := src.ReadFile(undo)
+	if err != nil {
+		return nil, err
+	}
+
+	mig.Down = string(down)
+	mig.DownLine = 1
+	mig.DownFile = undo
+
+	return mig, nil
+}
+
+func (flywayFormat) Create(dir string, version string, name string) ([]string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("V%s__%s.sql", version, strings.ReplaceAll(name, "-", "_")))
+	if err := createFile(path, []byte(upTemplate)); err != nil {
+		return nil, err
+	}
+
+	return []string{path}, nil
+}
+
+// findFlywayUndo returns the undo file for a version, or an empty string when
+// there is none.
+func findFlywayUndo(src source.Source, version string) (string, error) {
+	files, err := src.Files()
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range files {
+		match := flywayUndoPattern.FindStringSubmatch(file)
+		if match != nil && normalizeVersion(match[1]) == version {
+			return file, nil
+		}
+	}
+
+	return "", nil
+}
+
+// flywayVersion returns the normalized version of a Flyway versioned
+// migration name.
+func flywayVersion(name string) (string, bool) {
+	match := flywayVersionedPattern.FindStringSubmatch(name)
+	if match == nil {
+		return "", false
+	}
+
+	return normalizeVersion(match[1]), true
+}
+
+// normalizeVersion writes a Flyway version with dots, without leading zeros
+// and without trailing zero segments, so "1_02.0" and "1.2" compare equal.
+func normalizeVersion(version string) string {
+	segments := strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '_' })
+	for i, segment := range segments {
+		segments[i] = strings.TrimLeft(segment, "0")
+		if segments[i] == "" {
+			segments[i] = "0"
+		}
+	}
+
+	for len(segments) > 1 && segments[len(segments)-1] == "0" {
+		segments = segments[:len(segments)-1]
+	}
+
+	return strings.Join(segments, ".")
+}
+
+// compareVersions compares normalized versions segment by segment as
+// numbers of any length.
+func compareVersions(a string, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := "0", "0"
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+
+		if len(x) != len(y) {
+			if len(x) < len(y) {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// isRepeatable reports whether a migration is a Flyway repeatable migration.
+func isRepeatable(migration string) bool {
+	return flywayRepeatablePattern.MatchString(migration)
+}
+
+// leadingVersionPattern matches the version at the start of single file and
+// paired migration names, like "20230723123031" or the unpadded "10" of
+// "10_orders".
+var leadingVersionPattern = regexp.MustCompile(`^\d+`)
+
+// migrationVersion returns the normalized numeric version of a migration
+// name: the Flyway version, the version of a Go migration, or the digits a
+// name starts with.
+func migrationVersion(name string) (string, bool) {
+	if version, ok := flywayVersion(name); ok {
+		return version, true
+	}
+
+	if version, ok := goMigrationVersion(name); ok {
+		return version, true
+	}
+
+	if version := leadingVersionPattern.FindString(name); version != "" {
+		return normalizeVersion(version), true
+	}
+
+	return "", false
+}
+
+// compareMigrations orders migration names. Versioned names compare by
+// version as numbers, whatever their format, and then by name. Names without
+// a version come after them, compared as strings, and repeatable migrations
+// come after every other migration.
+func compareMigrations(a string, b string) int {
+	if ra, rb := migrationRank(a), migrationRank(b); ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	va, aVersioned := migrationVersion(a)
+	vb, bVersioned := migrationVersion(b)
+	if aVersioned && bVersioned {
+		if c := compareVersions(va, vb); c != 0 {
+			return c
+		}
+	}
+
+	return strings.Compare(a, b)
+}
+
+// migrationRank groups names for compareMigrations: versioned migrations,
+// then unversioned ones, then repeatable ones.
+func migrationRank(name string) int {
+	if isRepeatable(name) {
+		return 2
+	}
+
+	if _, ok := migrationVersion(name); ok {
+		return 0
+	}
+
+	return 1
+}
+
+// sortMigrations sorts migration names in the order they are applied.
+func sortMigrations(migrations []string) {
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return compareMigrations(migrations[i], migrations[j]) < 0
+	})
+}
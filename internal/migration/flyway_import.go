@@ -0,0 +1,219 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+// flywayHistoryRow is a row of a Flyway schema history table.
+type flywayHistoryRow struct {
+	Version       sql.NullString
+	Type          string
+	Script        string
+	Checksum      sql.NullInt64
+	InstalledBy   sql.NullString
+	InstalledOn   sql.NullTime
+	ExecutionTime sql.NullInt64
+	Success       bool
+}
+
+// ImportFlywayHistory records the migrations applied by Flyway, read from its
+// schema history table, as applied in the migrations table, so gomigrate can
+// take over a database Flyway used to manage. Migrations already recorded are
+// left as they are. It returns the migrations it recorded.
+func ImportFlywayHistory(ctx context.Context, table string, conf *config.Config) ([]string, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, release, err := openLocked(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	history, err := readFlywayHistory(ctx, driver, table)
+	if err != nil {
+		return nil, err
+	}
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := flywayAppliedMigrations(history, localMigrations, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedMigrations, err := driver.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := make(map[string]bool, len(appliedMigrations))
+	for _, migration := range appliedMigrations {
+		recorded[migration.Name] = true
+	}
+
+	local := make(map[string]bool, len(localMigrations))
+	for _, migration := range localMigrations {
+		local[migration] = true
+	}
+
+	imported := []string{}
+	for _, record := range records {
+		if recorded[record.Name] {
+			logf(conf, "== %s: already recorded, skipped\n", record.Name)
+			continue
+		}
+
+		if local[record.Name] {
+			mig, err := readMigrationFile(record.Name, conf)
+			if err != nil {
+				return nil, err
+			}
+
+			// A file changed since Flyway ran it keeps the Flyway checksum,
+			// so it is reported as modified, or run again when repeatable.
+			if record.Checksum == "" || record.Checksum == flywayChecksum(mig.Up) {
+				record.Checksum = mig.Checksum()
+			} else {
+				logf(conf, "Warning: %s changed since Flyway applied it.\n", record.Name)
+			}
+		} else {
+			logf(conf, "Warning: %s is not in %s, it is recorded without a checksum.\n", record.Name, migrationSource(conf))
+		}
+
+		record.ToolVersion = conf.Version
+		record.Reason = "imported from " + table
+		if conf.Reason != "" {
+			record.Reason = conf.Reason
+		}
+
+		if err := driver.MarkAsApplied(ctx, record); err != nil {
+			return imported, err
+		}
+
+		logf(conf, "== %s: imported\n", record.Name)
+		imported = append(imported, record.Name)
+	}
+
+	logf(conf, "Imported %d migrations from %s.\n", len(imported), table)
+
+	return imported, nil
+}
+
+// flywayChecksum computes the checksum Flyway records for a migration file,
+// the CRC32 of its lines without line endings, written like the checksums
+// read from the history table.
+func flywayChecksum(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+	content = strings.TrimPrefix(content, "\uFEFF")
+	content = strings.TrimSuffix(content, "\n")
+
+	hash := crc32.NewIEEE()
+	for _, line := range strings.Split(content, "\n") {
+		hash.Write([]byte(line))
+	}
+
+	return fmt.Sprintf("flyway:%d", int32(hash.Sum32()))
+}
+
+func readFlywayHistory(ctx context.Context, driver database.Driver, table string) ([]flywayHistoryRow, error) {
+	rows, err := driver.Query(ctx, `SELECT version, type, script, checksum, installed_by, installed_on, execution_time, success FROM `+table+` ORDER BY installed_rank`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Flyway history table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	history := []flywayHistoryRow{}
+	for rows.Next() {
+		var row flywayHistoryRow
+		if err := rows.Scan(&row.Version, &row.Type, &row.Script, &row.Checksum, &row.InstalledBy, &row.InstalledOn, &row.ExecutionTime, &row.Success); err != nil {
+			return nil, fmt.Errorf("failed to read the Flyway history table %s: %w", table, err)
+		}
+		history = append(history, row)
+	}
+
+	return history, rows.Err()
+}
+
+// flywayAppliedMigrations replays the Flyway history, in installed rank
+// order, into the migrations it leaves applied. Undone and deleted versions
+// are dropped, a repeatable migration keeps its latest run, and a baseline
+// marks every local versioned migration up to its version as applied.
+func flywayAppliedMigrations(history []flywayHistoryRow, localMigrations []string, conf *config.Config) ([]database.AppliedMigration, error) {
+	applied := map[string]database.AppliedMigration{}
+	byVersion := map[string]string{}
+
+	for _, row := range history {
+		if !row.Success {
+			return nil, fmt.Errorf("the Flyway history has a failed migration, %s, run \"flyway repair\" before importing it", row.Script)
+		}
+
+		record := database.AppliedMigration{
+			Name:      path.Base(row.Script),
+			AppliedAt: row.InstalledOn.Time,
+			Duration:  time.Duration(row.ExecutionTime.Int64) * time.Millisecond,
+			AppliedBy: row.InstalledBy.String,
+		}
+		if row.Checksum.Valid {
+			record.Checksum = fmt.Sprintf("flyway:%d", int32(row.Checksum.Int64))
+		}
+		version := normalizeVersion(row.Version.String)
+
+		switch row.Type {
+		case "SQL", "SQL_BASELINE":
+			if row.Version.Valid {
+				byVersion[version] = record.Name
+			}
+			applied[record.Name] = record
+
+		case "UNDO_SQL", "DELETE":
+			if name, ok := byVersion[version]; ok && row.Version.Valid {
+				delete(applied, name)
+				delete(byVersion, version)
+			} else {
+				delete(applied, record.Name)
+			}
+
+		case "BASELINE":
+			for _, migration := range localMigrations {
+				if local, ok := flywayVersion(migration); ok && compareVersions(local, version) <= 0 {
+					baseline := record
+					baseline.Name = migration
+					applied[migration] = baseline
+				}
+			}
+
+		case "SCHEMA":
+			// Flyway records the schemas it created, there is nothing to import.
+
+		default:
+			logf(conf, "Warning: %s is a %s migration, only SQL migrations can be imported.\n", row.Script, row.Type)
+		}
+	}
+
+	names := make([]string, 0, len(applied))
+	for name := range applied {
+		names = append(names, name)
+	}
+	sortMigrations(names)
+
+	records := make([]database.AppliedMigration, len(names))
+	for i, name := range names {
+		records[i] = applied[name]
+	}
+
+	return records, nil
+}
@@ -0,0 +1,104 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "1", want: "1"},
+		{version: "1.2", want: "1.2"},
+		{version: "1_2", want: "1.2"},
+		{version: "01.002", want: "1.2"},
+		{version: "1.2.0.0", want: "1.2"},
+		{version: "0", want: "0"},
+		{version: "000", want: "0"},
+		{version: "20230723123031", want: "20230723123031"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeVersion(tt.version); got != tt.want {
+			t.Errorf("normalizeVersion(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1", b: "1", want: 0},
+		{a: "1", b: "2", want: -1},
+		{a: "2", b: "10", want: -1},
+		{a: "10", b: "9", want: 1},
+		{a: "1.2", b: "1.10", want: -1},
+		{a: "1.2", b: "1.2.1", want: -1},
+		{a: "1", b: "1.0", want: 0},
+		{a: "20230723123031", b: "99999999999999999999", want: -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortMigrations(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []string
+		want       []string
+	}{
+		{
+			name:       "single file timestamps",
+			migrations: []string{"20230801000000-b.sql", "20230723123031-a.sql"},
+			want:       []string{"20230723123031-a.sql", "20230801000000-b.sql"},
+		},
+		{
+			name:       "unpadded paired names",
+			migrations: []string{"10_orders", "1_init", "2_users"},
+			want:       []string{"1_init", "2_users", "10_orders"},
+		},
+		{
+			name:       "padded and unpadded versions",
+			migrations: []string{"010_c", "9_b", "0001_a"},
+			want:       []string{"0001_a", "9_b", "010_c"},
+		},
+		{
+			name:       "same version ordered by name",
+			migrations: []string{"1_b", "01_a", "1_a"},
+			want:       []string{"01_a", "1_a", "1_b"},
+		},
+		{
+			name:       "flyway versions",
+			migrations: []string{"V10__c.sql", "V1.5__b.sql", "V2__b.sql", "V1__a.sql", "V1_1__a.sql"},
+			want:       []string{"V1__a.sql", "V1_1__a.sql", "V1.5__b.sql", "V2__b.sql", "V10__c.sql"},
+		},
+		{
+			name:       "repeatable migrations last",
+			migrations: []string{"R__views.sql", "V2__b.sql", "R__functions.sql", "V1__a.sql"},
+			want:       []string{"V1__a.sql", "V2__b.sql", "R__functions.sql", "R__views.sql"},
+		},
+		{
+			name:       "unversioned names after versioned ones",
+			migrations: []string{"init.sql", "2-b.sql", "cleanup.sql", "1-a.sql"},
+			want:       []string{"1-a.sql", "2-b.sql", "cleanup.sql", "init.sql"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := append([]string{}, tt.migrations...)
+			sortMigrations(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortMigrations(%q) = %q, want %q", tt.migrations, got, tt.want)
+			}
+		})
+	}
+}
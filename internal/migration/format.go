@@ -0,0 +1,137 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/source"
+)
+
+// Migration formats, set with "format" in the config file. When unset the
+// format is detected from the files in the migrations folder.
+const (
+	FormatSingle = "single"
+	FormatPaired = "paired"
+	FormatFlyway = "flyway"
+)
+
+// Format is a layout of migration files in the migrations folder. Migrations
+// are known by name, the name recorded in the migrations table, and each
+// format decides which files a name maps to.
+type Format interface {
+	// List returns the names of the migrations in src, sorted.
+	List(src source.Source) ([]string, error)
+
+	// Read loads a migration listed in src.
+	Read(src source.Source, migration string) (*Migration, error)
+
+	// Create writes the files of a new, empty migration in the dir folder,
+	// and returns their paths.
+	Create(dir string, version string, name string) ([]string, error)
+}
+
+var formats = map[string]Format{
+	FormatSingle: singleFileFormat{},
+	FormatPaired: pairedFileFormat{},
+	FormatFlyway: flywayFormat{},
+}
+
+// migrationFormat returns the format set in the config, or detects it from
+// the migrations folder.
+func migrationFormat(conf *config.Config) (Format, error) {
+	name := conf.Format
+	if name == "" {
+		detected, err := detectFormat(migrationSource(conf))
+		if err != nil {
+			return nil, err
+		}
+		name = detected
+	}
+
+	format, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown migration format %q, expected \"single\", \"paired\" or \"flyway\"", name)
+	}
+
+	return format, nil
+}
+
+// detectFormat picks the paired format when the folder has ".up.sql" or
+// ".down.sql" files, the Flyway format when it has Flyway named files, and
+// the single file format otherwise. A folder mixing layouts needs the format
+// set explicitly.
+func detectFormat(src source.Source) (string, error) {
+	files, err := src.Files()
+	if err != nil {
+		return "", err
+	}
+
+	examples := map[string]string{}
+	for _, name := range files {
+		switch {
+		case !strings.HasSuffix(name, ".sql"):
+		case strings.HasSuffix(name, upSuffix) || strings.HasSuffix(name, downSuffix):
+			examples[FormatPaired] = name
+		case flywayVersionedPattern.MatchString(name) || flywayUndoPattern.MatchString(name) || isRepeatable(name):
+			examples[FormatFlyway] = name
+		default:
+			examples[FormatSingle] = name
+		}
+	}
+
+	if len(examples) > 1 {
+		found := []string{}
+		for _, example := range examples {
+			found = append(found, example)
+		}
+		sort.Strings(found)
+		return "", fmt.Errorf("the migrations folder mixes migration formats (%s), set \"format\" in the config file", strings.Join(found, ", "))
+	}
+
+	for format := range examples {
+		return format, nil
+	}
+
+	return FormatSingle, nil
+}
+
+// migrationSource returns the source set in the config, or the migrations
+// folder.
+func migrationSource(conf *config.Config) source.Source {
+	if conf.Source != nil {
+		return conf.Source
+	}
+
+	return source.Dir(conf.MigrationsPath)
+}
+
+// loadMigrationScripts lists the migrations of the migrations folder along
+// with the registered Go migrations.
+func loadMigrationScripts(conf *config.Config) ([]string, error) {
+	format, err := migrationFormat(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := format.List(migrationSource(conf))
+	if err != nil {
+		return nil, err
+	}
+
+	return addGoMigrations(migrations)
+}
+
+func readMigrationFile(migration string, conf *config.Config) (*Migration, error) {
+	if registered, ok := goMigrations()[migration]; ok {
+		return readGoMigration(registered), nil
+	}
+
+	format, err := migrationFormat(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Read(migrationSource(conf), migration)
+}
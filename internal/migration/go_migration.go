@@ -0,0 +1,61 @@
+package migration
+
+import (
+	"fmt"
+
+	"github.com/allanmaral/gomigrate/gomigration"
+)
+
+// goMigrations returns the registered Go migrations by the name they are
+// recorded under.
+func goMigrations() map[string]gomigration.Migration {
+	registered := map[string]gomigration.Migration{}
+	for _, migration := range gomigration.Migrations() {
+		registered[migration.Name()] = migration
+	}
+	return registered
+}
+
+// goMigrationVersion returns the normalized version of a registered Go
+// migration.
+func goMigrationVersion(name string) (string, bool) {
+	migration, ok := goMigrations()[name]
+	if !ok {
+		return "", false
+	}
+
+	return normalizeVersion(migration.Version), true
+}
+
+// addGoMigrations adds the registered Go migrations to the migrations read
+// from the migrations folder, in order.
+func addGoMigrations(migrations []string) ([]string, error) {
+	registered := goMigrations()
+	if len(registered) == 0 {
+		return migrations, nil
+	}
+
+	for _, migration := range migrations {
+		if _, ok := registered[migration]; ok {
+			return nil, fmt.Errorf("%s is both a file in the migrations folder and a registered Go migration", migration)
+		}
+	}
+
+	all := append([]string{}, migrations...)
+	for name := range registered {
+		all = append(all, name)
+	}
+	sortMigrations(all)
+
+	return all, nil
+}
+
+func readGoMigration(migration gomigration.Migration) *Migration {
+	return &Migration{
+		UpFile:       migration.Name(),
+		DownFile:     migration.Name(),
+		UpFunc:       migration.Up,
+		DownFunc:     migration.Down,
+		Irreversible: migration.Down == nil,
+	}
+}
@@ -0,0 +1,90 @@
+package migration
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"sort"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+type HistoryEntry struct {
+	Name        string     `json:"name"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	DurationMs  int64      `json:"duration_ms"`
+	ToolVersion string     `json:"tool_version,omitempty"`
+	AppliedBy   string     `json:"applied_by,omitempty"`
+	Hostname    string     `json:"hostname,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	Dirty       bool       `json:"dirty,omitempty"`
+}
+
+// History lists the applied migrations in the order they were applied, with
+// the details recorded for each.
+func History(ctx context.Context, conf *config.Config) ([]HistoryEntry, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, appliedMigrations, err := openReadOnly(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	// Rows recorded by older versions have no timestamp and keep their name
+	// order ahead of the others.
+	sort.SliceStable(appliedMigrations, func(i, j int) bool {
+		return appliedMigrations[i].AppliedAt.Before(appliedMigrations[j].AppliedAt)
+	})
+
+	entries := make([]HistoryEntry, len(appliedMigrations))
+	for i, migration := range appliedMigrations {
+		entries[i] = HistoryEntry{
+			Name:        migration.Name,
+			DurationMs:  migration.Duration.Milliseconds(),
+			ToolVersion: migration.ToolVersion,
+			AppliedBy:   migration.AppliedBy,
+			Hostname:    migration.Hostname,
+			Reason:      migration.Reason,
+			Dirty:       migration.Dirty,
+		}
+		if !migration.AppliedAt.IsZero() {
+			appliedAt := migration.AppliedAt
+			entries[i].AppliedAt = &appliedAt
+		}
+	}
+
+	return entries, nil
+}
+
+// appliedRecord builds the migrations table row for a migration that started
+// running at started and has just finished.
+func appliedRecord(migration string, mig *Migration, started time.Time, conf *config.Config) database.AppliedMigration {
+	hostname, _ := os.Hostname()
+
+	return database.AppliedMigration{
+		Name:        migration,
+		Checksum:    mig.Checksum(),
+		AppliedAt:   time.Now(),
+		Duration:    time.Since(started),
+		ToolVersion: conf.Version,
+		AppliedBy:   currentUser(),
+		Hostname:    hostname,
+		Reason:      conf.Reason,
+	}
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+
+	return os.Getenv("USERNAME")
+}
@@ -0,0 +1,14 @@
+package migration
+
+import (
+	"fmt"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+)
+
+// logf writes a progress message to conf.Log, when set.
+func logf(conf *config.Config, format string, args ...any) {
+	if conf.Log != nil {
+		fmt.Fprintf(conf.Log, format, args...)
+	}
+}
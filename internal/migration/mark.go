@@ -0,0 +1,202 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+// ErrAborted is returned when the change to the history was not confirmed.
+var ErrAborted = errors.New("aborted, the migration history was not changed")
+
+// MarkApplied records a migration as applied without running it, for changes
+// made to the database by hand. The name, or version, must match a local
+// migration unless allowMissing is set, in which case an unknown name is
+// recorded as given, without a checksum. It returns the recorded name.
+func MarkApplied(ctx context.Context, name string, allowMissing bool, conf *config.Config) (string, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, release, err := openLocked(ctx, conf)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	appliedMigrations, err := driver.AppliedMigrations(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return "", err
+	}
+
+	migration, local, err := resolveMarked(name, localMigrations, allowMissing, conf)
+	if err != nil {
+		return "", err
+	}
+
+	for _, applied := range appliedMigrations {
+		if applied.Name == migration {
+			return "", fmt.Errorf("migration %s is already applied", migration)
+		}
+	}
+
+	if err := confirmEdit(fmt.Sprintf("Record %q as applied without running it?", migration), conf); err != nil {
+		return "", err
+	}
+
+	mig := &Migration{}
+	if local {
+		mig, err = readMigrationFile(migration, conf)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	record := appliedRecord(migration, mig, time.Now(), conf)
+	if !local {
+		// There is no file to take the checksum of.
+		record.Checksum = ""
+	}
+	if record.Reason == "" {
+		record.Reason = "marked as applied by hand"
+	}
+
+	if err := driver.MarkAsApplied(ctx, record); err != nil {
+		return "", err
+	}
+
+	logf(conf, "== %s: marked as applied (%s)\n", migration, record.Reason)
+
+	return migration, nil
+}
+
+// UnmarkApplied removes a migration from the migrations table without
+// reverting it, for changes undone by hand. The name is looked up like in
+// MarkApplied, with allowMissing looking among the applied migrations as
+// well. It returns the removed name. The row of the migration is deleted, and
+// who removed it, when and why is recorded in the unmarked table instead.
+func UnmarkApplied(ctx context.Context, name string, allowMissing bool, conf *config.Config) (string, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, release, err := openLocked(ctx, conf)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	appliedMigrations, err := driver.AppliedMigrations(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return "", err
+	}
+
+	known := localMigrations
+	if allowMissing {
+		known = append(findMissingMigrations(localMigrations, appliedNames(appliedMigrations)), localMigrations...)
+		sortMigrations(known)
+	}
+
+	migration, _, err := resolveMarked(name, known, allowMissing, conf)
+	if err != nil {
+		return "", err
+	}
+
+	applied := false
+	for _, recorded := range appliedMigrations {
+		if recorded.Name == migration {
+			applied = true
+			break
+		}
+	}
+	if !applied {
+		return "", fmt.Errorf("migration %s has not been applied", migration)
+	}
+
+	if err := confirmEdit(fmt.Sprintf("Remove %q from the migration history without reverting it?", migration), conf); err != nil {
+		return "", err
+	}
+
+	// The table is created before the transaction, as some engines commit
+	// DDL implicitly.
+	if err := driver.EnsureUnmarkedTable(ctx); err != nil {
+		return "", err
+	}
+
+	hostname, _ := os.Hostname()
+	unmarked := database.Unmarked{
+		Name:        migration,
+		UnmarkedAt:  time.Now(),
+		ToolVersion: conf.Version,
+		UnmarkedBy:  currentUser(),
+		Hostname:    hostname,
+		Reason:      conf.Reason,
+	}
+
+	err = withTransaction(ctx, driver, func(tx database.Tx) error {
+		if err := tx.RemoveApplied(ctx, migration); err != nil {
+			return err
+		}
+		return tx.RecordUnmarked(ctx, unmarked)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	reason := conf.Reason
+	if reason == "" {
+		reason = "unmarked by hand"
+	}
+	logf(conf, "== %s: marked as not applied (%s)\n", migration, reason)
+
+	return migration, nil
+}
+
+// resolveMarked finds the migration a mark or unmark name refers to, and
+// whether it is one of migrations. With allowMissing, a name matching none of
+// them is returned as given.
+func resolveMarked(name string, migrations []string, allowMissing bool, conf *config.Config) (string, bool, error) {
+	if len(matchTarget(name, migrations)) == 0 {
+		if allowMissing {
+			return name, false, nil
+		}
+		return "", false, fmt.Errorf("migration %s was not found in %s, use --allow-missing to use it anyway", name, migrationSource(conf))
+	}
+
+	migration, err := resolveTarget(name, migrations)
+	if err != nil {
+		return "", false, err
+	}
+
+	return migration, true, nil
+}
+
+// confirmEdit asks conf.Confirm, when set, before the history is edited.
+func confirmEdit(question string, conf *config.Config) error {
+	if conf.Confirm == nil {
+		return nil
+	}
+
+	ok, err := conf.Confirm(question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAborted
+	}
+
+	return nil
+}
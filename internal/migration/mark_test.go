@@ -0,0 +1,211 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+func TestMarkApplied(t *testing.T) {
+	tests := []struct {
+		name         string
+		migration    string
+		allowMissing bool
+		want         []string
+		wantErr      string
+	}{
+		{
+			name:      "local migration",
+			migration: "002",
+			want:      []string{"002-b.sql"},
+		},
+		{
+			name:      "unknown migration",
+			migration: "004-d.sql",
+			wantErr:   "migration 004-d.sql was not found in ., use --allow-missing to use it anyway",
+		},
+		{
+			name:         "unknown migration allowed",
+			migration:    "004-d.sql",
+			allowMissing: true,
+			want:         []string{"004-d.sql"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := testConfig(t, testMigrations)
+
+			_, err := MarkApplied(context.Background(), tt.migration, tt.allowMissing, conf)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("MarkApplied() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MarkApplied() error = %v", err)
+			}
+
+			if got := appliedList(t, conf); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applied migrations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkAppliedConfirm(t *testing.T) {
+	conf := testConfig(t, testMigrations)
+	conf.Confirm = func(question string) (bool, error) {
+		return false, nil
+	}
+
+	if _, err := MarkApplied(context.Background(), "001", false, conf); !errors.Is(err, ErrAborted) {
+		t.Fatalf("MarkApplied() error = %v, want ErrAborted", err)
+	}
+	if got := appliedList(t, conf); len(got) != 0 {
+		t.Errorf("applied migrations = %v, want none when not confirmed", got)
+	}
+}
+
+func TestUnmarkApplied(t *testing.T) {
+	conf := testConfig(t, testMigrations)
+	if _, err := RunMigrations(context.Background(), Target{}, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Reason = "undone by hand"
+	migration, err := UnmarkApplied(context.Background(), "002", false, conf)
+	if err != nil {
+		t.Fatalf("UnmarkApplied() error = %v", err)
+	}
+	if migration != "002-b.sql" {
+		t.Errorf("UnmarkApplied() = %q, want 002-b.sql", migration)
+	}
+
+	if got, want := appliedList(t, conf), []string{"001-a.sql", "003-c.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applied migrations = %v, want %v", got, want)
+	}
+
+	if got, want := unmarkedRows(t, conf), []string{"002-b.sql: undone by hand"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unmarked rows = %v, want %v", got, want)
+	}
+
+	if _, err := UnmarkApplied(context.Background(), "002", false, conf); err == nil || err.Error() != "migration 002-b.sql has not been applied" {
+		t.Errorf("UnmarkApplied() again error = %v, want it refused", err)
+	}
+}
+
+// unmarkedRows reads the name and reason of the rows of the unmarked table.
+func unmarkedRows(t *testing.T, conf *config.Config) []string {
+	t.Helper()
+
+	driver, err := database.Open(context.Background(), conf.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	rows, err := driver.Query(context.Background(), `SELECT name, reason FROM schema_migrations_unmarked ORDER BY name;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	got := []string{}
+	for rows.Next() {
+		var name, reason string
+		if err := rows.Scan(&name, &reason); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, name+": "+reason)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func TestBaseline(t *testing.T) {
+	conf := testConfig(t, testMigrations)
+
+	baselined, err := Baseline(context.Background(), "002", false, conf)
+	if err != nil {
+		t.Fatalf("Baseline() error = %v", err)
+	}
+	if want := []string{"001-a.sql", "002-b.sql"}; !reflect.DeepEqual(baselined, want) {
+		t.Errorf("Baseline() = %v, want %v", baselined, want)
+	}
+
+	// The baselined migrations were recorded without running, so only the
+	// pending one runs.
+	result, err := RunMigrations(context.Background(), Target{}, conf)
+	if err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	if got, want := resultNames(result), []string{"003-c.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunMigrations() ran %v, want %v", got, want)
+	}
+
+	if _, err := Baseline(context.Background(), "003", false, conf); err == nil {
+		t.Error("Baseline() on a database with history error = nil, want it refused")
+	}
+
+	baselined, err = Baseline(context.Background(), "003", true, conf)
+	if err != nil {
+		t.Fatalf("Baseline() with force error = %v", err)
+	}
+	if len(baselined) != 0 {
+		t.Errorf("Baseline() with force = %v, want the recorded migrations skipped", baselined)
+	}
+}
+
+func TestForceVersion(t *testing.T) {
+	conf := testConfig(t, map[string]string{
+		"001-a.sql": testMigrations["001-a.sql"],
+		"002-b.sql": "-- gomigrate:no-transaction\n" + singleMigration("CREATE TABLE b (id INTEGER);\nINSERT INTO missing VALUES (1);", "DROP TABLE b;"),
+		"003-c.sql": testMigrations["003-c.sql"],
+	})
+
+	if _, err := RunMigrations(context.Background(), Target{}, conf); err == nil {
+		t.Fatal("RunMigrations() error = nil, want the failure of 002-b.sql")
+	}
+
+	statuses, err := Status(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[1].Dirty {
+		t.Errorf("Status() of 002-b.sql = %+v, want it dirty", statuses[1])
+	}
+
+	_, err = RunMigrations(context.Background(), Target{}, conf)
+	if err == nil || !strings.HasPrefix(err.Error(), "migration 002-b.sql failed partway through") {
+		t.Fatalf("RunMigrations() on a dirty database error = %v, want it refused", err)
+	}
+
+	// Forcing the last fully applied version records 002-b.sql as not
+	// applied, so its fixed version runs again.
+	cleared, err := ForceVersion(context.Background(), "001", conf)
+	if err != nil {
+		t.Fatalf("ForceVersion() error = %v", err)
+	}
+	if want := []string{"002-b.sql"}; !reflect.DeepEqual(cleared, want) {
+		t.Errorf("ForceVersion() = %v, want %v", cleared, want)
+	}
+	if got, want := appliedList(t, conf), []string{"001-a.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applied migrations = %v, want %v", got, want)
+	}
+
+	// Table b was created before the failing statement and is still there.
+	setFile(t, conf, "002-b.sql", singleMigration("CREATE TABLE IF NOT EXISTS b (id INTEGER);", "DROP TABLE b;"))
+	if _, err := RunMigrations(context.Background(), Target{}, conf); err != nil {
+		t.Errorf("RunMigrations() after force error = %v", err)
+	}
+}
@@ -1,11 +1,16 @@
 package migration
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/allanmaral/gomigrate/gomigration"
 	"github.com/allanmaral/gomigrate/internal/config"
 	"github.com/allanmaral/gomigrate/internal/database"
 )
@@ -13,77 +18,224 @@ import (
 type Migration struct {
 	Up   string
 	Down string
+
+	// UpLine and DownLine are the lines of the file each section starts on.
+	UpLine   uint
+	DownLine uint
+
+	// UpFile and DownFile are the files each section was read from. They are
+	// the same file unless the migration format splits the sections.
+	UpFile   string
+	DownFile string
+
+	// NoTransaction is set by the "-- gomigrate:no-transaction" directive, for
+	// statements that cannot run inside a transaction.
+	NoTransaction bool
+
+	// Irreversible is set by the "-- gomigrate:irreversible" directive, for
+	// migrations that have no way back. They cannot be reverted.
+	Irreversible bool
+
+	// UpFunc and DownFunc are set instead of the sections for migrations
+	// written in Go.
+	UpFunc   gomigration.Func
+	DownFunc gomigration.Func
+}
+
+// directivePattern matches option comments like "-- gomigrate:no-transaction"
+// placed before the UP section.
+var directivePattern = regexp.MustCompile(`^--\s*gomigrate:(.*)$`)
+
+// Checksum hashes the UP and DOWN sections, so changes made to a migration
+// after it was applied can be detected. Line endings are normalised, so the
+// same file checked out with CRLF endings keeps its checksum.
+//
+// Migrations written in Go have no content to hash and no checksum.
+func (m *Migration) Checksum() string {
+	if m.UpFunc != nil {
+		return ""
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(strings.ReplaceAll(m.Up, "\r\n", "\n")))
+	hash.Write([]byte{0})
+	hash.Write([]byte(strings.ReplaceAll(m.Down, "\r\n", "\n")))
+	return hex.EncodeToString(hash.Sum(nil))
 }
 
-func openDbConnection(conf *config.Config) (database.Driver, error) {
-	driver, err := database.Open(conf.Url)
+// openReadOnly connects to the database and reads the applied migrations
+// without changing the migrations table, for dry runs and the commands that
+// only report on the history.
+func openReadOnly(ctx context.Context, conf *config.Config) (database.Driver, []database.AppliedMigration, error) {
+	driver, err := database.Open(ctx, conf.Url)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return driver, nil
+	appliedMigrations, err := readAppliedMigrations(ctx, driver)
+	if err != nil {
+		driver.Close()
+		return nil, nil, err
+	}
+
+	return driver, appliedMigrations, nil
 }
 
-func loadMigrationScripts(path string) ([]string, error) {
-	pattern := "*.sql"
-	files, err := os.ReadDir(path)
+// readAppliedMigrations reads the migrations table as it is. A missing table
+// has no rows, and one created by an older version is read by name only, like
+// the rows such versions recorded; its columns are added by the next command
+// that takes the lock.
+func readAppliedMigrations(ctx context.Context, driver database.Driver) ([]database.AppliedMigration, error) {
+	state, err := driver.CheckTable(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan migrations folder")
+		return nil, err
 	}
 
-	matchingFiles := []string{}
-	for _, file := range files {
-		match, err := filepath.Match(pattern, file.Name())
+	switch state {
+	case database.TableMissing:
+		return []database.AppliedMigration{}, nil
+	case database.TableOutdated:
+		names, err := driver.AppliedNames(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to match migration names")
+			return nil, err
 		}
 
-		if match {
-			matchingFiles = append(matchingFiles, file.Name())
+		appliedMigrations := make([]database.AppliedMigration, len(names))
+		for i, name := range names {
+			appliedMigrations[i] = database.AppliedMigration{Name: name}
 		}
+		return appliedMigrations, nil
 	}
 
-	return matchingFiles, nil
+	return driver.AppliedMigrations(ctx)
 }
 
-func readMigrationFile(migration string, conf *config.Config) (*Migration, error) {
-	dat, err := os.ReadFile(filepath.Join(conf.MigrationsPath, migration))
+// openLocked connects to the database, takes the migrations lock and only
+// then creates or upgrades the migrations table, so instances starting
+// together do not race on its DDL. The returned function releases the lock
+// and closes the connection.
+func openLocked(ctx context.Context, conf *config.Config) (database.Driver, func(), error) {
+	driver, err := database.Open(ctx, conf.Url)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	fileStr := string(dat)
-	sections := strings.Split(fileStr, "BEGIN -- UP")
-	if len(sections) < 2 {
-		return nil, fmt.Errorf("could not find start of UP section in file %s", migration)
+	if err := driver.Lock(ctx, conf.LockTimeout); err != nil {
+		driver.Close()
+		return nil, nil, err
 	}
 
-	fileStr = sections[1]
-	sections = strings.Split(fileStr, "END -- UP")
-	if len(sections) < 2 {
-		return nil, fmt.Errorf("could not find end of UP section in file %s", migration)
+	release := func() {
+		unlock(driver)
+		driver.Close()
 	}
 
-	up := sections[0]
+	if err := driver.EnsureTable(ctx); err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	return driver, release, nil
+}
 
-	fileStr = sections[1]
-	sections = strings.Split(fileStr, "BEGIN -- DOWN")
-	if len(sections) < 2 {
-		return nil, fmt.Errorf("could not find start of DOWN section in file %s", migration)
+// executor runs migrations and their bookkeeping, either directly on the
+// driver connection or inside one of its transactions.
+type executor interface {
+	Run(ctx context.Context, migration string) error
+	MarkAsApplied(ctx context.Context, migration database.AppliedMigration) error
+	RemoveApplied(ctx context.Context, migration string) error
+}
+
+// unlock releases the migrations lock. It does not take the run context, as
+// the lock still has to be released after the run is cancelled.
+func unlock(driver database.Driver) {
+	driver.Unlock(context.Background())
+}
+
+// inTransaction runs fn inside a driver transaction, so a migration and its
+// bookkeeping are committed or rolled back together. Migrations that opt out
+// of transactions, and drivers that cannot roll back schema changes, run fn
+// directly on the connection.
+func inTransaction(ctx context.Context, driver database.Driver, mig *Migration, fn func(ex executor) error) error {
+	if !runsInTransaction(driver, mig) {
+		return fn(driver)
 	}
 
-	fileStr = sections[1]
-	sections = strings.Split(fileStr, "END -- DOWN")
-	if len(sections) < 2 {
-		return nil, fmt.Errorf("could not find end of DOWN section in file %s", migration)
+	return withTransaction(ctx, driver, func(tx database.Tx) error {
+		return fn(tx)
+	})
+}
+
+// withTransaction runs fn inside a driver transaction, committing it when fn
+// succeeds and rolling it back otherwise. Go migrations always run this way,
+// as they are handed the transaction.
+func withTransaction(ctx context.Context, driver database.Driver, fn func(tx database.Tx) error) error {
+	tx, err := driver.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		// A cancelled ctx has already rolled the transaction back.
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// runsInTransaction reports whether a failed or interrupted migration is
+// rolled back as a whole.
+func runsInTransaction(driver database.Driver, mig *Migration) bool {
+	if mig.UpFunc != nil || mig.DownFunc != nil {
+		return true
 	}
 
-	down := sections[0]
+	return !mig.NoTransaction && driver.Transactional()
+}
+
+func warnIfNotTransactional(driver database.Driver, conf *config.Config) {
+	if !driver.Transactional() {
+		logf(conf, "Warning: this database cannot roll back schema changes, a failed migration may be left partially applied.\n")
+	}
+}
+
+// fileError points a driver error at the migration file, converting the line
+// the driver reports, which is relative to the section it ran, into a line of
+// the file.
+func fileError(file string, sectionLine uint, err error) error {
+	var dbErr database.Error
+	if errors.As(err, &dbErr) && dbErr.Line > 0 {
+		return fmt.Errorf("%s:%d: %w", file, sectionLine+dbErr.Line-1, err)
+	}
 
-	mig := &Migration{
-		Up:   up,
-		Down: down,
+	return fmt.Errorf("%s: %w", file, err)
+}
+
+// parseDirectives reads the option comments in the header of a migration
+// file, the text before the UP section, into mig.
+func parseDirectives(migration string, header string, mig *Migration) error {
+	for i, line := range strings.Split(header, "\n") {
+		match := directivePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		switch directive := strings.TrimSpace(match[1]); directive {
+		case "no-transaction":
+			mig.NoTransaction = true
+		case "irreversible":
+			mig.Irreversible = true
+		default:
+			return fmt.Errorf("%s:%d: unknown directive %q", migration, i+1, directive)
+		}
 	}
 
-	return mig, nil
+	return nil
 }
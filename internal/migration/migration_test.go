@@ -0,0 +1,361 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/source"
+
+	_ "github.com/allanmaral/gomigrate/internal/database/sqlite"
+)
+
+// testMigrations are single file migrations creating one table each.
+var testMigrations = map[string]string{
+	"001-a.sql": singleMigration("CREATE TABLE a (id INTEGER);", "DROP TABLE a;"),
+	"002-b.sql": singleMigration("CREATE TABLE b (id INTEGER);", "DROP TABLE b;"),
+	"003-c.sql": singleMigration("CREATE TABLE c (id INTEGER);", "DROP TABLE c;"),
+}
+
+func singleMigration(up string, down string) string {
+	return "BEGIN -- UP\n" + up + "\nEND -- UP\n\nBEGIN -- DOWN\n" + down + "\nEND -- DOWN\n"
+}
+
+// testConfig migrates a new SQLite database in a temporary folder, reading
+// the migrations from files.
+func testConfig(t *testing.T, files map[string]string) *config.Config {
+	t.Helper()
+
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	return &config.Config{
+		Url:         "sqlite://" + filepath.Join(t.TempDir(), "test.db"),
+		Source:      source.FS(fsys, "."),
+		LockTimeout: time.Second,
+		Version:     "test",
+	}
+}
+
+// setFile adds or replaces a migration file of a config made by testConfig.
+func setFile(t *testing.T, conf *config.Config, name string, content string) {
+	t.Helper()
+
+	files, err := conf.Source.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{}
+	for _, file := range files {
+		data, err := conf.Source.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys[file] = &fstest.MapFile{Data: data}
+	}
+	fsys[name] = &fstest.MapFile{Data: []byte(content)}
+
+	conf.Source = source.FS(fsys, ".")
+}
+
+// appliedList reads the names recorded in the migrations table.
+func appliedList(t *testing.T, conf *config.Config) []string {
+	t.Helper()
+
+	driver, appliedMigrations, err := openReadOnly(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("openReadOnly() error = %v", err)
+	}
+	defer driver.Close()
+
+	return appliedNames(appliedMigrations)
+}
+
+func resultNames(result *Result) []string {
+	names := []string{}
+	for _, migration := range result.Migrations {
+		names = append(names, migration.Name)
+	}
+	return names
+}
+
+func TestRunMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  Target
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "all pending",
+			target: Target{},
+			want:   []string{"001-a.sql", "002-b.sql", "003-c.sql"},
+		},
+		{
+			name:   "to a version",
+			target: Target{To: "002"},
+			want:   []string{"001-a.sql", "002-b.sql"},
+		},
+		{
+			name:   "to a full name",
+			target: Target{To: "001-a.sql"},
+			want:   []string{"001-a.sql"},
+		},
+		{
+			name:   "steps",
+			target: Target{Steps: 2},
+			want:   []string{"001-a.sql", "002-b.sql"},
+		},
+		{
+			name:   "more steps than pending",
+			target: Target{Steps: 5},
+			want:   []string{"001-a.sql", "002-b.sql", "003-c.sql"},
+		},
+		{
+			name:    "unknown target",
+			target:  Target{To: "004"},
+			wantErr: "unknown target migration \"004\"",
+		},
+		{
+			name:    "to and steps",
+			target:  Target{To: "002", Steps: 1},
+			wantErr: "only one of --to, --steps and --all can be used at a time",
+		},
+		{
+			name:    "all",
+			target:  Target{All: true},
+			wantErr: "--all can only be used to revert migrations",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := testConfig(t, testMigrations)
+
+			result, err := RunMigrations(context.Background(), tt.target, conf)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("RunMigrations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunMigrations() error = %v", err)
+			}
+
+			if got := resultNames(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RunMigrations() ran %v, want %v", got, tt.want)
+			}
+			if got := appliedList(t, conf); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applied migrations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunMigrationsFailure(t *testing.T) {
+	conf := testConfig(t, map[string]string{
+		"001-a.sql": testMigrations["001-a.sql"],
+		"002-b.sql": singleMigration("CREATE TABLE b (id INTEGER);\nINSERT INTO missing VALUES (1);", "DROP TABLE b;"),
+	})
+
+	result, err := RunMigrations(context.Background(), Target{}, conf)
+	if err == nil {
+		t.Fatal("RunMigrations() error = nil, want the failure of 002-b.sql")
+	}
+	if !strings.HasPrefix(err.Error(), "002-b.sql:") {
+		t.Errorf("RunMigrations() error = %q, want it to name 002-b.sql", err)
+	}
+
+	if got, want := resultNames(result), []string{"001-a.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunMigrations() ran %v, want %v", got, want)
+	}
+
+	// The failed migration ran in a transaction, so its table was rolled back
+	// along with its record.
+	if got, want := appliedList(t, conf), []string{"001-a.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applied migrations = %v, want %v", got, want)
+	}
+
+	setFile(t, conf, "002-b.sql", testMigrations["002-b.sql"])
+	if _, err := RunMigrations(context.Background(), Target{}, conf); err != nil {
+		t.Fatalf("RunMigrations() after the fix error = %v", err)
+	}
+}
+
+func TestRunMigrationsModified(t *testing.T) {
+	conf := testConfig(t, testMigrations)
+
+	if _, err := RunMigrations(context.Background(), Target{Steps: 1}, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	setFile(t, conf, "001-a.sql", singleMigration("CREATE TABLE a (id INTEGER, name TEXT);", "DROP TABLE a;"))
+
+	_, err := RunMigrations(context.Background(), Target{}, conf)
+	if err == nil || !strings.Contains(err.Error(), "001-a.sql") {
+		t.Fatalf("RunMigrations() error = %v, want the modified 001-a.sql", err)
+	}
+
+	if err := VerifyMigrations(context.Background(), conf); err == nil {
+		t.Error("VerifyMigrations() error = nil, want the modified 001-a.sql")
+	}
+
+	if _, err := RepairChecksums(context.Background(), nil, conf); err != nil {
+		t.Fatalf("RepairChecksums() error = %v", err)
+	}
+	if err := VerifyMigrations(context.Background(), conf); err != nil {
+		t.Errorf("VerifyMigrations() after repair error = %v", err)
+	}
+}
+
+func TestRevertMigration(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  Target
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "latest",
+			target: Target{},
+			want:   []string{"003-c.sql"},
+		},
+		{
+			name:   "to a version",
+			target: Target{To: "001"},
+			want:   []string{"003-c.sql", "002-b.sql"},
+		},
+		{
+			name:   "steps",
+			target: Target{Steps: 2},
+			want:   []string{"003-c.sql", "002-b.sql"},
+		},
+		{
+			name:   "all",
+			target: Target{All: true},
+			want:   []string{"003-c.sql", "002-b.sql", "001-a.sql"},
+		},
+		{
+			name:    "negative steps",
+			target:  Target{Steps: -1},
+			wantErr: "--steps must be a positive number, got -1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := testConfig(t, testMigrations)
+			if _, err := RunMigrations(context.Background(), Target{}, conf); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := RevertMigration(context.Background(), tt.target, conf)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("RevertMigration() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RevertMigration() error = %v", err)
+			}
+
+			if got := resultNames(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RevertMigration() reverted %v, want %v", got, tt.want)
+			}
+
+			remaining := len(testMigrations) - len(tt.want)
+			if got := appliedList(t, conf); len(got) != remaining {
+				t.Errorf("applied migrations = %v, want %d left", got, remaining)
+			}
+		})
+	}
+}
+
+func TestRevertIrreversible(t *testing.T) {
+	conf := testConfig(t, map[string]string{
+		"001-a.sql": "-- gomigrate:irreversible\nBEGIN -- UP\nCREATE TABLE a (id INTEGER);\nEND -- UP\n",
+	})
+	if _, err := RunMigrations(context.Background(), Target{}, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RevertMigration(context.Background(), Target{}, conf); err == nil {
+		t.Error("RevertMigration() error = nil, want the irreversible migration refused")
+	}
+	if got, want := appliedList(t, conf), []string{"001-a.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applied migrations = %v, want %v", got, want)
+	}
+}
+
+func TestRedoMigrations(t *testing.T) {
+	conf := testConfig(t, testMigrations)
+	if _, err := RunMigrations(context.Background(), Target{}, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := RedoMigrations(context.Background(), 2, conf)
+	if err != nil {
+		t.Fatalf("RedoMigrations() error = %v", err)
+	}
+
+	got := []string{}
+	for _, migration := range result.Migrations {
+		got = append(got, migration.Direction+" "+migration.Name)
+	}
+	want := []string{"down 003-c.sql", "down 002-b.sql", "up 002-b.sql", "up 003-c.sql"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RedoMigrations() ran %v, want %v", got, want)
+	}
+
+	if got, want := appliedList(t, conf), []string{"001-a.sql", "002-b.sql", "003-c.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("applied migrations = %v, want %v", got, want)
+	}
+
+	if _, err := RedoMigrations(context.Background(), 0, conf); err == nil {
+		t.Error("RedoMigrations(0) error = nil, want the steps refused")
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	conf := testConfig(t, testMigrations)
+
+	var script bytes.Buffer
+	conf.DryRun = &script
+
+	result, err := RunMigrations(context.Background(), Target{To: "002"}, conf)
+	if err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	if !result.DryRun {
+		t.Error("RunMigrations() result is not marked as a dry run")
+	}
+	if got, want := resultNames(result), []string{"001-a.sql", "002-b.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunMigrations() wrote %v, want %v", got, want)
+	}
+
+	for _, want := range []string{"CREATE TABLE IF NOT EXISTS \"schema_migrations\"", "-- 001-a.sql (UP)", "CREATE TABLE b (id INTEGER);"} {
+		if !strings.Contains(script.String(), want) {
+			t.Errorf("dry run script lacks %q:\n%s", want, script.String())
+		}
+	}
+	if strings.Contains(script.String(), "003-c.sql") {
+		t.Errorf("dry run script has 003-c.sql past the target:\n%s", script.String())
+	}
+
+	// Nothing was run, not even the creation of the migrations table.
+	conf.DryRun = nil
+	if got := appliedList(t, conf); len(got) != 0 {
+		t.Errorf("applied migrations = %v, want none after a dry run", got)
+	}
+}
@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+)
+
+// Policies for pending migrations that sort before the latest applied one,
+// set with "out_of_order" in the config file or the --out-of-order flag.
+const (
+	OutOfOrderError = "error"
+	OutOfOrderWarn  = "warn"
+	OutOfOrderAllow = "allow"
+)
+
+// findOutOfOrderMigrations returns the pending migrations older than the
+// latest applied migration, which usually come from a merged branch.
+// Repeatable migrations have no place in the order and are left out.
+func findOutOfOrderMigrations(applied []string, pending []string) []string {
+	latest := ""
+	for _, migration := range applied {
+		if !isRepeatable(migration) && (latest == "" || compareMigrations(migration, latest) > 0) {
+			latest = migration
+		}
+	}
+
+	outOfOrder := []string{}
+	for _, migration := range pending {
+		if latest != "" && !isRepeatable(migration) && compareMigrations(migration, latest) < 0 {
+			outOfOrder = append(outOfOrder, migration)
+		}
+	}
+
+	return outOfOrder
+}
+
+// checkOutOfOrder applies the configured out of order policy to the
+// migrations about to run. An empty policy defaults to warn.
+func checkOutOfOrder(applied []string, pending []string, conf *config.Config) error {
+	policy := conf.OutOfOrder
+	if policy == "" {
+		policy = OutOfOrderWarn
+	}
+
+	switch policy {
+	case OutOfOrderError, OutOfOrderWarn, OutOfOrderAllow:
+	default:
+		return fmt.Errorf("unknown out_of_order policy %q, expected \"error\", \"warn\" or \"allow\"", policy)
+	}
+
+	outOfOrder := findOutOfOrderMigrations(applied, pending)
+	if len(outOfOrder) == 0 || policy == OutOfOrderAllow {
+		return nil
+	}
+
+	if policy == OutOfOrderError {
+		return fmt.Errorf("pending migrations are older than the latest applied migration: %s\nrun with --out-of-order=allow to apply them anyway", strings.Join(outOfOrder, ", "))
+	}
+
+	for _, migration := range outOfOrder {
+		logf(conf, "Warning: %s is older than the latest applied migration, applying it out of order.\n", migration)
+	}
+
+	return nil
+}
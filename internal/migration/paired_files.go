@@ -0,0 +1,143 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/source"
+)
+
+const (
+	upSuffix   = ".up.sql"
+	downSuffix = ".down.sql"
+)
+
+var upTemplate = `--  Add altering commands here.
+-- 
+--  Example:
+--  CREATE TABLE users (
+--    user_id INT,
+--    last_name VARCHAR(255),
+--    first_name VARCHAR(255),
+--    created_at TIMESTAMPTZ 
+--  );
+`
+
+var downTemplate = `-- Add reverting commands here.
+--
+-- Example:
+-- DROP TABLE users;
+`
+
+// pairedFileFormat keeps each section of a migration in its own file, like
+// "001_create_users.up.sql" and "001_create_users.down.sql". The migration
+// name is the file name without the suffix. The down file may only be left
+// out of migrations marked with "-- gomigrate:irreversible".
+type pairedFileFormat struct{}
+
+func (f pairedFileFormat) List(src source.Source) ([]string, error) {
+	files, err := src.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	ups := map[string]bool{}
+	downs := map[string]bool{}
+	for _, name := range files {
+		switch {
+		case strings.HasSuffix(name, upSuffix):
+			ups[strings.TrimSuffix(name, upSuffix)] = true
+		case strings.HasSuffix(name, downSuffix):
+			downs[strings.TrimSuffix(name, downSuffix)] = true
+		}
+	}
+
+	for migration := range downs {
+		if !ups[migration] {
+			return nil, fmt.Errorf("%s%s has no matching %s%s", migration, downSuffix, migration, upSuffix)
+		}
+	}
+
+	migrations := []string{}
+	for migration := range ups {
+		// Reading the migration checks that a missing down file is marked
+		// irreversible.
+		if !downs[migration] {
+			if _, err := f.Read(src, migration); err != nil {
+				return nil, err
+			}
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sortMigrations(migrations)
+
+	return migrations, nil
+}
+
+func (pairedFileFormat) Read(src source.Source, migration string) (*Migration, error) {
+	upFile := migration + upSuffix
+	downFile := migration + downSuffix
+
+	up, err := src.ReadFile(upFile)
+	if err != nil {
+		return nil, err
+	}
+
+	mig := &Migration{Up: string(up), UpLine: 1, UpFile: upFile}
+	if err := parseDirectives(upFile, leadingComments(mig.Up), mig); err != nil {
+		return nil, err
+	}
+
+	down, err := src.ReadFile(downFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if !mig.Irreversible {
+			return nil, fmt.Errorf("%s has no matching %s, add one or mark the migration with \"-- gomigrate:irreversible\"", upFile, downFile)
+		}
+		return mig, nil
+
+	case err != nil:
+		return nil, err
+	}
+
+	if mig.Irreversible {
+		return nil, fmt.Errorf("%s is marked irreversible but %s exists", upFile, downFile)
+	}
+
+	mig.Down = string(down)
+	mig.DownLine = 1
+	mig.DownFile = downFile
+
+	return mig, nil
+}
+
+func (pairedFileFormat) Create(dir string, version string, name string) ([]string, error) {
+	base := filepath.Join(dir, fmt.Sprintf("%s_%s", version, strings.ReplaceAll(name, "-", "_")))
+
+	if err := createFile(base+upSuffix, []byte(upTemplate)); err != nil {
+		return nil, err
+	}
+	if err := createFile(base+downSuffix, []byte(downTemplate)); err != nil {
+		return nil, err
+	}
+
+	return []string{base + upSuffix, base + downSuffix}, nil
+}
+
+// leadingComments returns the blank and comment lines at the top of a file,
+// where directives go.
+func leadingComments(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			return strings.Join(lines[:i], "\n")
+		}
+	}
+
+	return content
+}
@@ -0,0 +1,145 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// markerPattern matches a section marker line like "BEGIN -- UP". Case and
+// spacing are not significant, but the marker has to be alone on its line.
+var markerPattern = regexp.MustCompile(`(?i)^\s*(BEGIN|END)\s*--\s*(UP|DOWN)\s*$`)
+
+// Parser states, in the order a migration file goes through them.
+const (
+	beforeUp = iota
+	inUp
+	beforeDown
+	inDown
+	afterDown
+)
+
+// section collects the body of an UP or DOWN section while parsing.
+type section struct {
+	body  strings.Builder
+	start int
+}
+
+// parseMigration reads the sections and directives of a migration file. Only
+// blank lines, comments and directives may appear outside the sections, and
+// each section must appear once, UP before DOWN. The DOWN section may only be
+// left out of irreversible migrations. Errors point at the file line that
+// caused them.
+//
+// A section body starts with whatever follows its BEGIN marker on the same
+// line, so line n of the body is line UpLine+n-1 of the file.
+func parseMigration(migration string, content string) (*Migration, error) {
+	mig := &Migration{}
+	lines := strings.Split(content, "\n")
+
+	state := beforeUp
+	var up, down section
+	header := []string{}
+
+	fail := func(line int, format string, args ...any) (*Migration, error) {
+		return nil, fmt.Errorf("%s:%d: %s", migration, line, fmt.Sprintf(format, args...))
+	}
+
+	for i, text := range lines {
+		lineNo := i + 1
+		match := markerPattern.FindStringSubmatchIndex(strings.TrimRight(text, "\r"))
+
+		if match == nil {
+			switch state {
+			case inUp:
+				up.body.WriteString(text + "\n")
+			case inDown:
+				down.body.WriteString(text + "\n")
+			default:
+				trimmed := strings.TrimSpace(text)
+				if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+					return fail(lineNo, "statement outside of the UP and DOWN sections")
+				}
+				if state == beforeUp {
+					header = append(header, text)
+				}
+			}
+			continue
+		}
+
+		// The text before the marker and after it, usually nothing but
+		// indentation and a carriage return, stays part of the body the way
+		// it always has, so checksums of applied migrations do not change.
+		indent, rest := text[:match[2]], text[match[5]:]
+
+		marker := strings.ToUpper(text[match[2]:match[3]]) + " -- " + strings.ToUpper(text[match[4]:match[5]])
+		switch marker {
+		case "BEGIN -- UP":
+			if state != beforeUp {
+				return fail(lineNo, "duplicate BEGIN -- UP marker, the UP section starts on line %d", up.start)
+			}
+			up.start = lineNo
+			up.body.WriteString(rest + "\n")
+			state = inUp
+
+		case "END -- UP":
+			switch state {
+			case beforeUp:
+				return fail(lineNo, "END -- UP marker without a matching BEGIN -- UP")
+			case beforeDown, inDown, afterDown:
+				return fail(lineNo, "duplicate END -- UP marker")
+			}
+			up.body.WriteString(indent)
+			state = beforeDown
+
+		case "BEGIN -- DOWN":
+			switch state {
+			case beforeUp:
+				return fail(lineNo, "the DOWN section must come after the UP section")
+			case inUp:
+				return fail(lineNo, "BEGIN -- DOWN marker inside the UP section, missing END -- UP")
+			case inDown, afterDown:
+				return fail(lineNo, "duplicate BEGIN -- DOWN marker, the DOWN section starts on line %d", down.start)
+			}
+			down.start = lineNo
+			down.body.WriteString(rest + "\n")
+			state = inDown
+
+		case "END -- DOWN":
+			switch state {
+			case beforeUp, inUp, beforeDown:
+				return fail(lineNo, "END -- DOWN marker without a matching BEGIN -- DOWN")
+			case afterDown:
+				return fail(lineNo, "duplicate END -- DOWN marker")
+			}
+			down.body.WriteString(indent)
+			state = afterDown
+		}
+	}
+
+	if err := parseDirectives(migration, strings.Join(header, "\n"), mig); err != nil {
+		return nil, err
+	}
+
+	switch state {
+	case beforeUp:
+		return fail(len(lines), "could not find the BEGIN -- UP marker")
+	case inUp:
+		return fail(up.start, "the UP section is never closed, missing END -- UP")
+	case beforeDown:
+		if !mig.Irreversible {
+			return fail(len(lines), "could not find the BEGIN -- DOWN marker, add one or mark the migration with \"-- gomigrate:irreversible\"")
+		}
+	case inDown:
+		return fail(down.start, "the DOWN section is never closed, missing END -- DOWN")
+	}
+
+	mig.Up = up.body.String()
+	mig.Down = down.body.String()
+	mig.UpLine = uint(up.start)
+	mig.DownLine = uint(down.start)
+	mig.UpFile = migration
+	mig.DownFile = migration
+
+	return mig, nil
+}
@@ -0,0 +1,136 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMigration(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Migration
+	}{
+		{
+			name:    "up and down",
+			content: "BEGIN -- UP\nCREATE TABLE a (id int);\nEND -- UP\n\nBEGIN -- DOWN\nDROP TABLE a;\nEND -- DOWN\n",
+			want:    Migration{Up: "\nCREATE TABLE a (id int);\n", Down: "\nDROP TABLE a;\n", UpLine: 1, DownLine: 5},
+		},
+		{
+			name:    "case and spacing",
+			content: "-- a comment\n\n  begin--up  \nSELECT 1;\n\tEnd  --  Up\nBEGIN -- down\nSELECT 2;\nend -- DOWN",
+			want:    Migration{Up: "  \nSELECT 1;\n\t", Down: "\nSELECT 2;\n", UpLine: 3, DownLine: 6},
+		},
+		{
+			name:    "markers not alone on their line",
+			content: "BEGIN -- UP\nSELECT 'END -- UP';\n/* BEGIN -- DOWN */\nEND -- UP\nBEGIN -- DOWN\nEND -- DOWN",
+			want:    Migration{Up: "\nSELECT 'END -- UP';\n/* BEGIN -- DOWN */\n", Down: "\n", UpLine: 1, DownLine: 5},
+		},
+		{
+			name:    "carriage returns",
+			content: "BEGIN -- UP\r\nSELECT 1;\r\nEND -- UP\r\nBEGIN -- DOWN\r\nSELECT 2;\r\nEND -- DOWN\r\n",
+			want:    Migration{Up: "\r\nSELECT 1;\r\n", Down: "\r\nSELECT 2;\r\n", UpLine: 1, DownLine: 4},
+		},
+		{
+			name:    "directives",
+			content: "-- gomigrate:no-transaction\n--gomigrate: irreversible\nBEGIN -- UP\nSELECT 1;\nEND -- UP\n",
+			want:    Migration{Up: "\nSELECT 1;\n", UpLine: 3, NoTransaction: true, Irreversible: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMigration("001-a.sql", tt.content)
+			if err != nil {
+				t.Fatalf("parseMigration() error = %v", err)
+			}
+
+			tt.want.UpFile = "001-a.sql"
+			tt.want.DownFile = "001-a.sql"
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("parseMigration()\n got %#v\nwant %#v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMigrationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "no UP section",
+			content: "-- nothing here\n",
+			want:    "001-a.sql:2: could not find the BEGIN -- UP marker",
+		},
+		{
+			name:    "statement before UP",
+			content: "SELECT 1;\nBEGIN -- UP\nEND -- UP\n",
+			want:    "001-a.sql:1: statement outside of the UP and DOWN sections",
+		},
+		{
+			name:    "statement between sections",
+			content: "BEGIN -- UP\nEND -- UP\nSELECT 1;\nBEGIN -- DOWN\nEND -- DOWN\n",
+			want:    "001-a.sql:3: statement outside of the UP and DOWN sections",
+		},
+		{
+			name:    "duplicate UP",
+			content: "BEGIN -- UP\nEND -- UP\nBEGIN -- UP\n",
+			want:    "001-a.sql:3: duplicate BEGIN -- UP marker, the UP section starts on line 1",
+		},
+		{
+			name:    "END UP without BEGIN",
+			content: "END -- UP\n",
+			want:    "001-a.sql:1: END -- UP marker without a matching BEGIN -- UP",
+		},
+		{
+			name:    "DOWN before UP",
+			content: "BEGIN -- DOWN\nEND -- DOWN\nBEGIN -- UP\nEND -- UP\n",
+			want:    "001-a.sql:1: the DOWN section must come after the UP section",
+		},
+		{
+			name:    "DOWN inside UP",
+			content: "BEGIN -- UP\nSELECT 1;\nBEGIN -- DOWN\n",
+			want:    "001-a.sql:3: BEGIN -- DOWN marker inside the UP section, missing END -- UP",
+		},
+		{
+			name:    "UP never closed",
+			content: "\nBEGIN -- UP\nSELECT 1;\n",
+			want:    "001-a.sql:2: the UP section is never closed, missing END -- UP",
+		},
+		{
+			name:    "no DOWN section",
+			content: "BEGIN -- UP\nEND -- UP\n",
+			want:    "001-a.sql:3: could not find the BEGIN -- DOWN marker, add one or mark the migration with \"-- gomigrate:irreversible\"",
+		},
+		{
+			name:    "DOWN never closed",
+			content: "BEGIN -- UP\nEND -- UP\nBEGIN -- DOWN\nSELECT 1;\n",
+			want:    "001-a.sql:3: the DOWN section is never closed, missing END -- DOWN",
+		},
+		{
+			name:    "duplicate END DOWN",
+			content: "BEGIN -- UP\nEND -- UP\nBEGIN -- DOWN\nEND -- DOWN\nEND -- DOWN\n",
+			want:    "001-a.sql:5: duplicate END -- DOWN marker",
+		},
+		{
+			name:    "unknown directive",
+			content: "-- comment\n-- gomigrate:sometimes\nBEGIN -- UP\nEND -- UP\n",
+			want:    "001-a.sql:2: unknown directive \"sometimes\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseMigration("001-a.sql", tt.content)
+			if err == nil {
+				t.Fatalf("parseMigration() error = nil, want %q", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("parseMigration() error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+)
+
+// RedoMigrations reverts the latest applied migrations and applies them again,
+// reading each file right before it runs so edits to the down and up sections
+// are picked up. Both passes run under the same lock.
+func RedoMigrations(ctx context.Context, steps int, conf *config.Config) (*Result, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("--steps must be a positive number, got %d", steps)
+	}
+
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, appliedMigrations, release, err := openRunConnection(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if len(appliedMigrations) == 0 {
+		logf(conf, "No executed migrations found.\n")
+		return &Result{}, nil
+	}
+
+	if err := checkDirty(appliedMigrations); err != nil {
+		return nil, err
+	}
+
+	redoMigrations, err := Target{Steps: steps}.reverting(revertibleNames(appliedNames(appliedMigrations)))
+	if err != nil {
+		return nil, err
+	}
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every migration has to be reverted and reapplied, so refuse to start
+	// when one of them is no longer in the migrations folder or cannot be
+	// reverted, instead of stopping halfway.
+	local := make(map[string]bool, len(localMigrations))
+	for _, migration := range localMigrations {
+		local[migration] = true
+	}
+	for _, migration := range redoMigrations {
+		if !local[migration] {
+			return nil, fmt.Errorf("cannot redo %s, the migration file is missing from %s", migration, migrationSource(conf))
+		}
+
+		mig, err := readMigrationFile(migration, conf)
+		if err != nil {
+			return nil, err
+		}
+
+		if mig.Irreversible {
+			return nil, fmt.Errorf("cannot redo %s, it is irreversible", migration)
+		}
+	}
+
+	if conf.DryRun != nil {
+		reapplied := make([]string, 0, len(redoMigrations))
+		for i := len(redoMigrations) - 1; i >= 0; i-- {
+			reapplied = append(reapplied, redoMigrations[i])
+		}
+		return writeScript(ctx, driver, redoMigrations, reapplied, conf)
+	}
+
+	warnIfNotTransactional(driver, conf)
+
+	start := time.Now()
+	result := &Result{}
+
+	for _, migration := range redoMigrations {
+		if err := ctx.Err(); err != nil {
+			return result, stoppedError(migration, err)
+		}
+
+		reverted, err := revertMigration(ctx, driver, migration, conf)
+		if err != nil {
+			return result, err
+		}
+		result.Migrations = append(result.Migrations, reverted)
+	}
+
+	for i := len(redoMigrations) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return result, stoppedError(redoMigrations[i], err)
+		}
+
+		applied, err := runMigration(ctx, driver, redoMigrations[i], conf)
+		if err != nil {
+			return result, err
+		}
+		result.Migrations = append(result.Migrations, applied)
+	}
+
+	elapsed := time.Since(start)
+	logf(conf, "Redid %d migration(s) (%s)\n", len(redoMigrations), elapsed)
+
+	return result, nil
+}
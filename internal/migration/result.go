@@ -0,0 +1,25 @@
+package migration
+
+import "time"
+
+// Directions a migration runs in.
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// MigrationResult describes a migration that was applied or reverted.
+type MigrationResult struct {
+	Name      string        `json:"name"`
+	Direction string        `json:"direction"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Result lists the migrations a run went through, in the order they ran.
+type Result struct {
+	Migrations []MigrationResult `json:"migrations"`
+
+	// DryRun is set when the migrations were written as SQL instead of being
+	// executed.
+	DryRun bool `json:"dry_run"`
+}
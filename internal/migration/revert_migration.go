@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -8,58 +9,110 @@ import (
 	"github.com/allanmaral/gomigrate/internal/database"
 )
 
-func RevertMigration(undoAll bool, conf *config.Config) error {
-	driver, err := openDbConnection(conf)
+// RevertMigration reverts the applied migrations selected by target, latest
+// first. The migrations reverted before an error are returned along with it.
+func RevertMigration(ctx context.Context, target Target, conf *config.Config) (*Result, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, appliedMigrations, release, err := openRunConnection(ctx, conf)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer release()
+
+	if len(appliedMigrations) == 0 {
+		logf(conf, "No executed migrations found.\n")
+		return &Result{}, nil
+	}
+
+	if err := checkDirty(appliedMigrations); err != nil {
+		return nil, err
 	}
-	defer driver.Close()
 
-	appliedMigrations, err := driver.AppliedMigrations()
+	revertingMigrations, err := target.reverting(revertibleNames(appliedNames(appliedMigrations)))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if len(appliedMigrations) == 0 {
-		fmt.Println("No executed migrations found.")
-		return nil
+	if len(revertingMigrations) == 0 {
+		logf(conf, "No migrations were reverted, the target is the latest applied migration.\n")
+		return &Result{}, nil
 	}
 
-	migrationsCount := len(appliedMigrations)
-	for k := range appliedMigrations {
-		i := migrationsCount - 1 - k
-		migration := appliedMigrations[i]
+	if conf.DryRun != nil {
+		return writeScript(ctx, driver, revertingMigrations, nil, conf)
+	}
 
-		err := revertMigration(driver, migration, conf)
-		if err != nil {
-			return err
+	warnIfNotTransactional(driver, conf)
+
+	result := &Result{}
+	for _, migration := range revertingMigrations {
+		if err := ctx.Err(); err != nil {
+			return result, stoppedError(migration, err)
 		}
 
-		if !undoAll {
-			break
+		reverted, err := revertMigration(ctx, driver, migration, conf)
+		if err != nil {
+			return result, err
 		}
+		result.Migrations = append(result.Migrations, reverted)
 	}
 
-	return nil
+	return result, nil
 }
 
-func revertMigration(driver database.Driver, migration string, conf *config.Config) error {
+func revertMigration(ctx context.Context, driver database.Driver, migration string, conf *config.Config) (MigrationResult, error) {
 	start := time.Now()
-	fmt.Printf("== %s: reverting =======\n", migration)
+	logf(conf, "== %s: reverting =======\n", migration)
 
 	mig, err := readMigrationFile(migration, conf)
 	if err != nil {
-		return err
+		return MigrationResult{}, err
 	}
 
-	if err := driver.Run(mig.Down); err != nil {
-		return err
+	ctx, cancel := migrationContext(ctx, conf)
+	defer cancel()
+
+	if mig.Irreversible {
+		return MigrationResult{}, fmt.Errorf("%s is irreversible and cannot be reverted", migration)
+	}
+
+	if mig.NoTransaction {
+		logf(conf, "== %s: running without a transaction\n", migration)
 	}
 
-	driver.RemoveApplied(migration)
+	if mig.DownFunc != nil {
+		err = withTransaction(ctx, driver, func(tx database.Tx) error {
+			if err := tx.RunFunc(ctx, mig.DownFunc); err != nil {
+				return fmt.Errorf("%s: %w", migration, err)
+			}
+
+			return tx.RemoveApplied(ctx, migration)
+		})
+	} else {
+		// Like runMigration, mark a down section that cannot be rolled back
+		// as dirty until it succeeds.
+		if !runsInTransaction(driver, mig) {
+			if err := driver.SetDirty(ctx, migration, true); err != nil {
+				return MigrationResult{}, err
+			}
+		}
+
+		err = inTransaction(ctx, driver, mig, func(ex executor) error {
+			if err := ex.Run(ctx, mig.Down); err != nil {
+				return fileError(mig.DownFile, mig.DownLine, err)
+			}
+
+			return ex.RemoveApplied(ctx, migration)
+		})
+	}
+	if err != nil {
+		return MigrationResult{}, interruptedError(ctx, driver, migration, mig, err)
+	}
 
 	elapsed := time.Since(start)
-	fmt.Printf("== %s: reverted (%s)\n", migration, elapsed)
+	logf(conf, "== %s: reverted (%s)\n", migration, elapsed)
 
-	return nil
+	return MigrationResult{Name: migration, Direction: DirectionDown, Duration: elapsed}, nil
 }
@@ -1,68 +1,155 @@
 package migration
 
 import (
+	"context"
 	"fmt"
-	"sort"
 	"time"
 
 	"github.com/allanmaral/gomigrate/internal/config"
 	"github.com/allanmaral/gomigrate/internal/database"
 )
 
-func RunMigrations(conf *config.Config) error {
-	driver, err := openDbConnection(conf)
+// RunMigrations applies the pending migrations selected by target, in order.
+// The migrations applied before an error are returned along with it.
+func RunMigrations(ctx context.Context, target Target, conf *config.Config) (*Result, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, appliedMigrations, release, err := openRunConnection(ctx, conf)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer driver.Close()
+	defer release()
 
-	appliedMigrations, err := driver.AppliedMigrations()
+	localMigrations, err := loadMigrationScripts(conf)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := checkDirty(appliedMigrations); err != nil {
+		return nil, err
+	}
+
+	modified, _, err := compareChecksums(appliedMigrations, localMigrations, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(modified) > 0 {
+		return nil, modifiedMigrationsError(modified)
 	}
 
-	localMigrations, err := loadMigrationScripts(conf.MigrationsPath)
+	changed, err := changedRepeatables(appliedMigrations, localMigrations, conf)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	missingMigrations := findMissingMigrations(appliedMigrations, localMigrations)
+	applied := appliedNames(appliedMigrations)
+	missingMigrations := findMissingMigrations(applied, localMigrations)
+	missingMigrations = append(missingMigrations, changed...)
+	sortMigrations(missingMigrations)
+
+	missingMigrations, err = target.pending(missingMigrations, localMigrations)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(missingMigrations) == 0 {
-		fmt.Println("No migrations were executed, database schema was already up to date.")
-		return nil
+		logf(conf, "No migrations were executed, database schema was already up to date.\n")
+		return &Result{}, nil
 	}
 
+	if err := checkOutOfOrder(applied, missingMigrations, conf); err != nil {
+		return nil, err
+	}
+
+	if conf.DryRun != nil {
+		return writeScript(ctx, driver, nil, missingMigrations, conf)
+	}
+
+	warnIfNotTransactional(driver, conf)
+
+	result := &Result{}
 	for _, migration := range missingMigrations {
-		if err := runMigration(driver, migration, conf); err != nil {
-			return err
+		if err := ctx.Err(); err != nil {
+			return result, stoppedError(migration, err)
+		}
+
+		applied, err := runMigration(ctx, driver, migration, conf)
+		if err != nil {
+			return result, err
 		}
+		result.Migrations = append(result.Migrations, applied)
 	}
 
-	return nil
+	return result, nil
 }
 
-func runMigration(driver database.Driver, migration string, conf *config.Config) error {
+func runMigration(ctx context.Context, driver database.Driver, migration string, conf *config.Config) (MigrationResult, error) {
 	start := time.Now()
-	fmt.Printf("== %s: migrating =======\n", migration)
+	logf(conf, "== %s: migrating =======\n", migration)
 
 	mig, err := readMigrationFile(migration, conf)
 	if err != nil {
-		return err
+		return MigrationResult{}, err
 	}
 
-	if err := driver.Run(mig.Up); err != nil {
-		return err
+	ctx, cancel := migrationContext(ctx, conf)
+	defer cancel()
+
+	if mig.NoTransaction {
+		logf(conf, "== %s: running without a transaction\n", migration)
 	}
 
-	if err := driver.MarkAsApplied(migration); err != nil {
-		return err
+	if mig.UpFunc != nil {
+		err = withTransaction(ctx, driver, func(tx database.Tx) error {
+			if err := tx.RunFunc(ctx, mig.UpFunc); err != nil {
+				return fmt.Errorf("%s: %w", migration, err)
+			}
+
+			return tx.MarkAsApplied(ctx, appliedRecord(migration, mig, start, conf))
+		})
+	} else {
+		// A migration that cannot be rolled back is recorded as dirty before
+		// it runs, so a failure halfway through is not forgotten.
+		dirty := !runsInTransaction(driver, mig)
+		if dirty {
+			if err := markDirty(ctx, driver, appliedRecord(migration, mig, start, conf)); err != nil {
+				return MigrationResult{}, err
+			}
+		}
+
+		err = inTransaction(ctx, driver, mig, func(ex executor) error {
+			if err := ex.Run(ctx, mig.Up); err != nil {
+				return fileError(mig.UpFile, mig.UpLine, err)
+			}
+
+			record := appliedRecord(migration, mig, start, conf)
+
+			// A migration recorded as dirty runs on the connection, and its
+			// row is completed in one statement so it is never lost.
+			if dirty {
+				return driver.UpdateApplied(ctx, record)
+			}
+
+			// A repeatable migration that ran before replaces its old record.
+			if isRepeatable(migration) {
+				if err := ex.RemoveApplied(ctx, migration); err != nil {
+					return err
+				}
+			}
+
+			return ex.MarkAsApplied(ctx, record)
+		})
+	}
+	if err != nil {
+		return MigrationResult{}, interruptedError(ctx, driver, migration, mig, err)
 	}
 
 	elapsed := time.Since(start)
-	fmt.Printf("== %s: migrated (%s)\n", migration, elapsed)
+	logf(conf, "== %s: migrated (%s)\n", migration, elapsed)
 
-	return nil
+	return MigrationResult{Name: migration, Direction: DirectionUp, Duration: elapsed}, nil
 }
 
 func findMissingMigrations(applied []string, available []string) []string {
@@ -77,7 +164,7 @@ func findMissingMigrations(applied []string, available []string) []string {
 		}
 	}
 
-	sort.Strings(missing)
+	sortMigrations(missing)
 
 	return missing
 }
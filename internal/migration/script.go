@@ -0,0 +1,136 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+// GenerateScript writes an idempotent script applying the local migrations
+// after from, up to and including to. Each migration is guarded by a check
+// against the migrations table, so the script can be run any number of times.
+// Both bounds are optional, and the database is never connected to. It
+// returns the migrations written to out. Repeatable migrations are left out,
+// as a guard on the name alone would never run them again once changed, and
+// migrations that run outside a transaction are refused. Nothing is written
+// to out unless every migration could be scripted.
+func GenerateScript(ctx context.Context, from string, to string, out io.Writer, conf *config.Config) ([]string, error) {
+	scripter, err := database.NewScripter(conf.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := scriptRange(from, to, localMigrations)
+	if err != nil {
+		return nil, err
+	}
+
+	var script strings.Builder
+	fmt.Fprintln(&script, "-- Generated by gomigrate. Safe to run more than once.")
+	fmt.Fprintln(&script, "-- Each migration is applied in its own transaction. Stop at the first error,")
+	fmt.Fprintln(&script, "-- like with psql -v ON_ERROR_STOP=1 or sqlcmd -b, as clients that carry on")
+	fmt.Fprintln(&script, "-- run the later migrations after a failed one was rolled back.")
+	fmt.Fprintln(&script)
+	fmt.Fprintln(&script, scripter.EnsureTableScript())
+
+	written := []string{}
+	for _, migration := range migrations {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if isRepeatable(migration) {
+			fmt.Fprintf(&script, "-- %s is a repeatable migration and was left out, apply it with \"gomigrate run\".\n\n", migration)
+			continue
+		}
+
+		mig, err := readMigrationFile(migration, conf)
+		if err != nil {
+			return nil, err
+		}
+
+		if mig.UpFunc != nil {
+			return nil, fmt.Errorf("%s is a Go migration and cannot be written as SQL", migration)
+		}
+
+		// The guard wraps the body in a block, which statements that must
+		// run outside a transaction are not allowed in either.
+		if mig.NoTransaction {
+			return nil, fmt.Errorf("%s runs outside a transaction and cannot be guarded in a script, use \"gomigrate run --dry-run\" instead", migration)
+		}
+
+		record := database.AppliedMigration{
+			Name:        migration,
+			Checksum:    mig.Checksum(),
+			ToolVersion: conf.Version,
+			Reason:      conf.Reason,
+		}
+
+		guarded, err := scripter.GuardedScript(record, mig.Up)
+		if errors.Is(err, database.ErrScriptNotSupported) {
+			return nil, fmt.Errorf("%w for this database, use \"gomigrate run --dry-run\" instead", err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", migration, err)
+		}
+
+		fmt.Fprintf(&script, "-- %s\n", migration)
+		fmt.Fprintln(&script, guarded)
+		written = append(written, migration)
+	}
+
+	if _, err := io.WriteString(out, script.String()); err != nil {
+		return nil, err
+	}
+
+	return written, nil
+}
+
+// scriptRange picks the migrations after from, up to and including to.
+func scriptRange(from string, to string, localMigrations []string) ([]string, error) {
+	migrations := append([]string{}, localMigrations...)
+	sortMigrations(migrations)
+
+	start := 0
+	if from != "" {
+		first, err := resolveTarget(from, migrations)
+		if err != nil {
+			return nil, err
+		}
+		start = indexOf(migrations, first) + 1
+	}
+
+	end := len(migrations)
+	if to != "" {
+		last, err := resolveTarget(to, migrations)
+		if err != nil {
+			return nil, err
+		}
+		end = indexOf(migrations, last) + 1
+	}
+
+	if start > end {
+		return nil, fmt.Errorf("migration %q comes after %q", from, to)
+	}
+
+	return migrations[start:end], nil
+}
+
+func indexOf(migrations []string, migration string) int {
+	for i, m := range migrations {
+		if m == migration {
+			return i
+		}
+	}
+	return -1
+}
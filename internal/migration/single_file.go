@@ -0,0 +1,79 @@
+package migration
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/allanmaral/gomigrate/internal/source"
+)
+
+var migrationTemplate = `
+BEGIN -- UP
+
+--  Add altering commands here.
+-- 
+--  Example:
+--  CREATE TABLE users (
+--    user_id INT,
+--    last_name VARCHAR(255),
+--    first_name VARCHAR(255),
+--    created_at TIMESTAMPTZ 
+--  );
+
+END -- UP
+
+
+BEGIN -- DOWN
+
+-- Add reverting commands here.
+--
+-- Example:
+-- DROP TABLE users;
+
+END -- DOWN
+`
+
+// singleFileFormat keeps both sections of a migration in one file, between
+// "BEGIN -- UP" and "BEGIN -- DOWN" markers. The migration name is the file
+// name.
+type singleFileFormat struct{}
+
+func (singleFileFormat) List(src source.Source) ([]string, error) {
+	pattern := "*.sql"
+	files, err := src.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	matchingFiles := []string{}
+	for _, file := range files {
+		match, err := filepath.Match(pattern, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match migration names")
+		}
+
+		if match {
+			matchingFiles = append(matchingFiles, file)
+		}
+	}
+
+	return matchingFiles, nil
+}
+
+func (singleFileFormat) Read(src source.Source, migration string) (*Migration, error) {
+	dat, err := src.ReadFile(migration)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseMigration(migration, string(dat))
+}
+
+func (singleFileFormat) Create(dir string, version string, name string) ([]string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.sql", version, name))
+	if err := createFile(path, []byte(migrationTemplate)); err != nil {
+		return nil, err
+	}
+
+	return []string{path}, nil
+}
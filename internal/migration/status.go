@@ -0,0 +1,117 @@
+package migration
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+)
+
+const (
+	StateApplied = "applied"
+	StatePending = "pending"
+	StateMissing = "missing"
+)
+
+type MigrationStatus struct {
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+
+	// Modified is set for applied migrations whose file no longer matches the
+	// checksum recorded when they were applied.
+	Modified bool `json:"modified"`
+
+	// OutOfOrder is set for pending migrations older than the latest applied
+	// migration.
+	OutOfOrder bool `json:"out_of_order"`
+
+	// Dirty is set for applied migrations that failed partway through, see
+	// ForceVersion.
+	Dirty bool `json:"dirty"`
+}
+
+// Status merges the local migration files with the migrations recorded in the
+// database, marking each as applied, pending, or applied but missing from the
+// migrations folder.
+func Status(ctx context.Context, conf *config.Config) ([]MigrationStatus, error) {
+	ctx, cancel := runContext(ctx, conf)
+	defer cancel()
+
+	driver, appliedMigrations, err := openReadOnly(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	localMigrations, err := loadMigrationScripts(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiedMigrations, _, err := compareChecksums(appliedMigrations, localMigrations, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]bool, len(localMigrations))
+	for _, migration := range localMigrations {
+		local[migration] = true
+	}
+
+	modified := make(map[string]bool, len(modifiedMigrations))
+	for _, migration := range modifiedMigrations {
+		modified[migration] = true
+	}
+
+	changed, err := changedRepeatables(appliedMigrations, localMigrations, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Changed repeatable migrations are listed once, as pending, since the
+	// next run applies them again.
+	rerun := make(map[string]bool, len(changed))
+	for _, migration := range changed {
+		rerun[migration] = true
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migration := range appliedMigrations {
+		if rerun[migration.Name] {
+			continue
+		}
+
+		state := StateApplied
+		if !local[migration.Name] {
+			state = StateMissing
+		}
+		status := MigrationStatus{Name: migration.Name, State: state, Modified: modified[migration.Name], Dirty: migration.Dirty}
+		if !migration.AppliedAt.IsZero() {
+			appliedAt := migration.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	pending := findMissingMigrations(appliedNames(appliedMigrations), localMigrations)
+	outOfOrder := make(map[string]bool)
+	for _, migration := range findOutOfOrderMigrations(appliedNames(appliedMigrations), pending) {
+		outOfOrder[migration] = true
+	}
+
+	for _, migration := range pending {
+		statuses = append(statuses, MigrationStatus{Name: migration, State: StatePending, OutOfOrder: outOfOrder[migration]})
+	}
+
+	for _, migration := range changed {
+		statuses = append(statuses, MigrationStatus{Name: migration, State: StatePending})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return compareMigrations(statuses[i].Name, statuses[j].Name) < 0
+	})
+
+	return statuses, nil
+}
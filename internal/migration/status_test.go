@@ -0,0 +1,300 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+func TestStatus(t *testing.T) {
+	conf := testConfig(t, map[string]string{
+		"001-a.sql": testMigrations["001-a.sql"],
+		"003-c.sql": testMigrations["003-c.sql"],
+	})
+	if _, err := RunMigrations(context.Background(), Target{}, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MarkApplied(context.Background(), "004-d.sql", true, conf); err != nil {
+		t.Fatal(err)
+	}
+	setFile(t, conf, "002-b.sql", testMigrations["002-b.sql"])
+	setFile(t, conf, "003-c.sql", singleMigration("CREATE TABLE c (id INTEGER, name TEXT);", "DROP TABLE c;"))
+
+	statuses, err := Status(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	got := []string{}
+	for _, status := range statuses {
+		line := status.Name + " " + status.State
+		if status.Modified {
+			line += " modified"
+		}
+		if status.OutOfOrder {
+			line += " out-of-order"
+		}
+		got = append(got, line)
+	}
+
+	want := []string{
+		"001-a.sql applied",
+		"002-b.sql pending out-of-order",
+		"003-c.sql applied modified",
+		"004-d.sql missing",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Status() = %v, want %v", got, want)
+	}
+}
+
+func TestStatusOutdatedTable(t *testing.T) {
+	conf := testConfig(t, testMigrations)
+
+	driver, err := database.Open(context.Background(), conf.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	// The migrations table as created by versions without history columns.
+	for _, query := range []string{
+		`CREATE TABLE schema_migrations (name VARCHAR(255) NOT NULL PRIMARY KEY);`,
+		`INSERT INTO schema_migrations (name) VALUES ('001-a.sql');`,
+	} {
+		if err := driver.Run(context.Background(), query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses, err := Status(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if len(statuses) != 3 || statuses[0].State != StateApplied || statuses[1].State != StatePending {
+		t.Errorf("Status() = %+v, want 001-a.sql applied and the others pending", statuses)
+	}
+
+	// Status only reads, so the table is left without its history columns.
+	state, err := driver.CheckTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != database.TableOutdated {
+		t.Errorf("CheckTable() after Status() = %v, want TableOutdated", state)
+	}
+
+	var script bytes.Buffer
+	conf.DryRun = &script
+	if _, err := RunMigrations(context.Background(), Target{}, conf); err != nil {
+		t.Fatalf("RunMigrations() dry run error = %v", err)
+	}
+	if !strings.Contains(script.String(), `ALTER TABLE "schema_migrations" ADD COLUMN checksum`) {
+		t.Errorf("dry run script does not upgrade the migrations table:\n%s", script.String())
+	}
+	if strings.Contains(script.String(), "-- 001-a.sql") {
+		t.Errorf("dry run script has the applied 001-a.sql:\n%s", script.String())
+	}
+
+	conf.DryRun = nil
+	result, err := RunMigrations(context.Background(), Target{}, conf)
+	if err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	if got, want := resultNames(result), []string{"002-b.sql", "003-c.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunMigrations() ran %v, want %v", got, want)
+	}
+}
+
+func TestOutOfOrder(t *testing.T) {
+	tests := []struct {
+		policy  string
+		wantLog string
+		wantErr string
+	}{
+		{
+			policy:  OutOfOrderError,
+			wantErr: "pending migrations are older than the latest applied migration: 002-b.sql\nrun with --out-of-order=allow to apply them anyway",
+		},
+		{
+			policy:  OutOfOrderWarn,
+			wantLog: "Warning: 002-b.sql is older than the latest applied migration, applying it out of order.\n",
+		},
+		{
+			policy: OutOfOrderAllow,
+		},
+		{
+			policy:  "sometimes",
+			wantErr: "unknown out_of_order policy \"sometimes\", expected \"error\", \"warn\" or \"allow\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			conf := testConfig(t, map[string]string{
+				"001-a.sql": testMigrations["001-a.sql"],
+				"003-c.sql": testMigrations["003-c.sql"],
+			})
+			if _, err := RunMigrations(context.Background(), Target{}, conf); err != nil {
+				t.Fatal(err)
+			}
+			setFile(t, conf, "002-b.sql", testMigrations["002-b.sql"])
+
+			var log bytes.Buffer
+			conf.Log = &log
+			conf.OutOfOrder = tt.policy
+
+			result, err := RunMigrations(context.Background(), Target{}, conf)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("RunMigrations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunMigrations() error = %v", err)
+			}
+
+			if got, want := resultNames(result), []string{"002-b.sql"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("RunMigrations() ran %v, want %v", got, want)
+			}
+			if got := strings.Contains(log.String(), "Warning:"); got != (tt.wantLog != "") {
+				t.Errorf("RunMigrations() log = %q, want warning %q", log.String(), tt.wantLog)
+			}
+			if tt.wantLog != "" && !strings.Contains(log.String(), tt.wantLog) {
+				t.Errorf("RunMigrations() log = %q, want %q", log.String(), tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestPairedFormat(t *testing.T) {
+	conf := testConfig(t, map[string]string{
+		"001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+		"001_a.down.sql": "DROP TABLE a;",
+		"002_b.up.sql":   "-- gomigrate:irreversible\nCREATE TABLE b (id INTEGER);",
+	})
+
+	result, err := RunMigrations(context.Background(), Target{}, conf)
+	if err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	if got, want := resultNames(result), []string{"001_a", "002_b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunMigrations() ran %v, want %v", got, want)
+	}
+
+	if _, err := RevertMigration(context.Background(), Target{}, conf); err == nil {
+		t.Error("RevertMigration() of the irreversible 002_b error = nil, want it refused")
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "down without up",
+			files: map[string]string{"001_a.down.sql": "DROP TABLE a;"},
+			want:  "001_a.down.sql has no matching 001_a.up.sql",
+		},
+		{
+			name:  "up without down",
+			files: map[string]string{"001_a.up.sql": "CREATE TABLE a (id INTEGER);"},
+			want:  "001_a.up.sql has no matching 001_a.down.sql, add one or mark the migration with \"-- gomigrate:irreversible\"",
+		},
+		{
+			name: "irreversible with down",
+			files: map[string]string{
+				"001_a.up.sql":   "-- gomigrate:irreversible\nCREATE TABLE a (id INTEGER);",
+				"001_a.down.sql": "DROP TABLE a;",
+			},
+			want: "001_a.up.sql is marked irreversible but 001_a.down.sql exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := testConfig(t, tt.files)
+			conf.Format = FormatPaired
+
+			_, err := RunMigrations(context.Background(), Target{}, conf)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("RunMigrations() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlywayFormat(t *testing.T) {
+	conf := testConfig(t, map[string]string{
+		"V1__a.sql":    "CREATE TABLE a (id INTEGER);",
+		"V1.1__b.sql":  "CREATE TABLE b (id INTEGER);",
+		"U1.1__b.sql":  "DROP TABLE b;",
+		"R__views.sql": "CREATE VIEW IF NOT EXISTS va AS SELECT id FROM a;",
+	})
+
+	result, err := RunMigrations(context.Background(), Target{}, conf)
+	if err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	if got, want := resultNames(result), []string{"V1__a.sql", "V1.1__b.sql", "R__views.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunMigrations() ran %v, want %v", got, want)
+	}
+
+	// A changed repeatable migration runs again.
+	setFile(t, conf, "R__views.sql", "DROP VIEW IF EXISTS va;\nCREATE VIEW va AS SELECT id FROM a WHERE id > 0;")
+	result, err = RunMigrations(context.Background(), Target{}, conf)
+	if err != nil {
+		t.Fatalf("RunMigrations() after changing R__views.sql error = %v", err)
+	}
+	if got, want := resultNames(result), []string{"R__views.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunMigrations() ran %v, want %v", got, want)
+	}
+
+	result, err = RevertMigration(context.Background(), Target{To: "1"}, conf)
+	if err != nil {
+		t.Fatalf("RevertMigration() error = %v", err)
+	}
+	if got, want := resultNames(result), []string{"V1.1__b.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RevertMigration() reverted %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "same version",
+			files: map[string]string{"V1__a.sql": "SELECT 1;", "V1_0__b.sql": "SELECT 1;"},
+			want:  "V1_0__b.sql and V1__a.sql have the same version 1",
+		},
+		{
+			name:  "undo without versioned migration",
+			files: map[string]string{"V1__a.sql": "SELECT 1;", "U2__b.sql": "SELECT 1;"},
+			want:  "U2__b.sql has no matching versioned migration",
+		},
+		{
+			name:  "not a Flyway name",
+			files: map[string]string{"V1__a.sql": "SELECT 1;", "b.sql": "SELECT 1;"},
+			want:  "b.sql is not a Flyway migration, expected a name like V1__description.sql or R__description.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := testConfig(t, tt.files)
+			conf.Format = FormatFlyway
+
+			_, err := RunMigrations(context.Background(), Target{}, conf)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("RunMigrations() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Target limits the migrations a run or undo goes through. Only one of its
+// fields may be set. The zero value applies every pending migration on run and
+// reverts the latest applied one on undo.
+type Target struct {
+	// To is a migration name or version, like "20230723123031". Run applies
+	// pending migrations up to and including it, undo reverts down to but not
+	// including it.
+	To string
+
+	// Steps is the number of migrations to apply or revert.
+	Steps int
+
+	// All reverts every applied migration on undo.
+	All bool
+}
+
+func (t Target) validate() error {
+	set := 0
+	if t.To != "" {
+		set++
+	}
+	if t.Steps != 0 {
+		set++
+	}
+	if t.All {
+		set++
+	}
+
+	if set > 1 {
+		return fmt.Errorf("only one of --to, --steps and --all can be used at a time")
+	}
+
+	if t.Steps < 0 {
+		return fmt.Errorf("--steps must be a positive number, got %d", t.Steps)
+	}
+
+	return nil
+}
+
+// pending picks the migrations to apply from the sorted pending migrations.
+// The To target is looked up among all the local migrations, so targeting a
+// migration that is already applied is not an error.
+func (t Target) pending(pending []string, local []string) ([]string, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case t.All:
+		return nil, fmt.Errorf("--all can only be used to revert migrations")
+
+	case t.Steps > 0:
+		if t.Steps < len(pending) {
+			return pending[:t.Steps], nil
+		}
+		return pending, nil
+
+	case t.To != "":
+		target, err := resolveTarget(t.To, local)
+		if err != nil {
+			return nil, err
+		}
+
+		selected := []string{}
+		for _, migration := range pending {
+			if compareMigrations(migration, target) > 0 {
+				break
+			}
+			selected = append(selected, migration)
+		}
+		return selected, nil
+	}
+
+	return pending, nil
+}
+
+// reverting picks the migrations to revert from the applied migrations, given
+// in the order they were applied, and returns them latest first.
+func (t Target) reverting(applied []string) ([]string, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+
+	reversed := make([]string, len(applied))
+	for i, migration := range applied {
+		reversed[len(applied)-1-i] = migration
+	}
+
+	switch {
+	case t.All:
+		return reversed, nil
+
+	case t.Steps > 0:
+		if t.Steps < len(reversed) {
+			return reversed[:t.Steps], nil
+		}
+		return reversed, nil
+
+	case t.To != "":
+		target, err := resolveTarget(t.To, applied)
+		if err != nil {
+			return nil, err
+		}
+
+		selected := []string{}
+		for _, migration := range reversed {
+			if migration == target {
+				break
+			}
+			selected = append(selected, migration)
+		}
+		return selected, nil
+	}
+
+	if len(reversed) > 1 {
+		return reversed[:1], nil
+	}
+	return reversed, nil
+}
+
+// resolveTarget finds the migration a --to value refers to. It matches a full
+// name, a name without its extension, the version prefix of a name, or the
+// version of a Flyway migration, like "1.2".
+func resolveTarget(target string, migrations []string) (string, error) {
+	matches := matchTarget(target, migrations)
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("unknown target migration %q", target)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("ambiguous target migration %q, it matches %s", target, strings.Join(matches, ", "))
+	}
+}
+
+// matchTarget returns the migrations a --to value may refer to, see
+// resolveTarget.
+func matchTarget(target string, migrations []string) []string {
+	matches := []string{}
+	for _, migration := range migrations {
+		if migration == target {
+			return []string{migration}
+		}
+
+		if version, ok := flywayVersion(migration); ok && version == normalizeVersion(strings.TrimPrefix(target, "V")) {
+			matches = append(matches, migration)
+			continue
+		}
+
+		if strings.HasPrefix(migration, target) {
+			rest := []rune(migration[len(target):])
+			if len(rest) == 0 || !unicode.IsLetter(rest[0]) && !unicode.IsDigit(rest[0]) {
+				matches = append(matches, migration)
+			}
+		}
+	}
+
+	return matches
+}
+
+// IsTarget reports whether a --to value refers to exactly one of migrations.
+func IsTarget(target string, migrations []string) bool {
+	_, err := resolveTarget(target, migrations)
+	return err == nil
+}
@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/allanmaral/gomigrate/internal/config"
+	"github.com/allanmaral/gomigrate/internal/database"
+)
+
+// runContext bounds ctx by the timeout of a whole command, when one is set.
+func runContext(ctx context.Context, conf *config.Config) (context.Context, context.CancelFunc) {
+	if conf.Timeout > 0 {
+		return context.WithTimeout(ctx, conf.Timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// migrationContext bounds ctx by the timeout of a single migration, when one
+// is set.
+func migrationContext(ctx context.Context, conf *config.Config) (context.Context, context.CancelFunc) {
+	if conf.MigrationTimeout > 0 {
+		return context.WithTimeout(ctx, conf.MigrationTimeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// interruptedError names the migration that was running when ctx ended, and
+// whether its changes were rolled back. Errors that happen while ctx is still
+// live are returned as they are.
+func interruptedError(ctx context.Context, driver database.Driver, migration string, mig *Migration, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+
+	reason := "was interrupted"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = "timed out"
+	}
+
+	state := "its changes were rolled back"
+	if !runsInTransaction(driver, mig) {
+		state = "it may be left partially applied"
+	}
+
+	return fmt.Errorf("migration %s %s, %s: %w", migration, reason, state, err)
+}
+
+// stoppedError reports a run that ended between migrations, before migration
+// started.
+func stoppedError(migration string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out before %s: %w", migration, err)
+	}
+
+	return fmt.Errorf("interrupted before %s: %w", migration, err)
+}
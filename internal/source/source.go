@@ -0,0 +1,63 @@
+package source
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// Source is where migration files are read from. Migration formats only look
+// at the files directly in it.
+type Source interface {
+	// Files returns the names of the files in the source, sorted.
+	Files() ([]string, error)
+
+	// ReadFile returns the content of a file returned by Files.
+	ReadFile(name string) ([]byte, error)
+
+	// String describes the source in messages, like a folder path.
+	String() string
+}
+
+// fsSource reads migrations from a directory of a fs.FS.
+type fsSource struct {
+	fsys fs.FS
+	dir  string
+	name string
+}
+
+// FS returns a Source reading the files in dir of fsys, like an embed.FS or a
+// fstest.MapFS. Use "." for the root of fsys.
+func FS(fsys fs.FS, dir string) Source {
+	return &fsSource{fsys: fsys, dir: dir, name: dir}
+}
+
+// Dir returns a Source reading the files of a folder.
+func Dir(dir string) Source {
+	return &fsSource{fsys: os.DirFS(dir), dir: ".", name: dir}
+}
+
+func (s *fsSource) Files() ([]string, error) {
+	entries, err := fs.ReadDir(s.fsys, s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan migrations folder \"%s\"", s.name)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+
+	return files, nil
+}
+
+func (s *fsSource) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, path.Join(s.dir, name))
+}
+
+func (s *fsSource) String() string {
+	return s.name
+}